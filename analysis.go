@@ -1,6 +1,10 @@
 package wechat
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/pkg/errors"
 )
@@ -40,50 +44,252 @@ const (
 	GetVisitPage
 )
 
-var datacubeURL = map[aims]string{
-	GetDailyRetain:       "https://api.weixin.qq.com/datacube/getweanalysisappiddailyretaininfo",
-	GetMonthlyRetain:     "https://api.weixin.qq.com/datacube/getweanalysisappidmonthlyretaininfo",
-	GetWeeklyRetain:      "https://api.weixin.qq.com/datacube/getweanalysisappidweeklyretaininfo",
-	GetDailySummary:      "https://api.weixin.qq.com/datacube/getweanalysisappiddailysummarytrend",
-	GetDailyVisitTrend:   "https://api.weixin.qq.com/datacube/getweanalysisappiddailyvisittrend",
-	GetMonthlyVisitTrend: "https://api.weixin.qq.com/datacube/getweanalysisappidmonthlyvisittrend",
-	GetWeeklyVisitTrend:  "https://api.weixin.qq.com/datacube/getweanalysisappidweeklyvisittrend",
-	GetUserPortrait:      "https://api.weixin.qq.com/datacube/getweanalysisappiduserportrait",
-	GetVisitDistribution: "https://api.weixin.qq.com/datacube/getweanalysisappidvisitdistribution",
-	GetVisitPage:         "https://api.weixin.qq.com/datacube/getweanalysisappidvisitpage",
+var datacubePath = map[aims]string{
+	GetDailyRetain:       "/datacube/getweanalysisappiddailyretaininfo",
+	GetMonthlyRetain:     "/datacube/getweanalysisappidmonthlyretaininfo",
+	GetWeeklyRetain:      "/datacube/getweanalysisappidweeklyretaininfo",
+	GetDailySummary:      "/datacube/getweanalysisappiddailysummarytrend",
+	GetDailyVisitTrend:   "/datacube/getweanalysisappiddailyvisittrend",
+	GetMonthlyVisitTrend: "/datacube/getweanalysisappidmonthlyvisittrend",
+	GetWeeklyVisitTrend:  "/datacube/getweanalysisappidweeklyvisittrend",
+	GetUserPortrait:      "/datacube/getweanalysisappiduserportrait",
+	GetVisitDistribution: "/datacube/getweanalysisappidvisitdistribution",
+	GetVisitPage:         "/datacube/getweanalysisappidvisitpage",
+}
+
+// datacubeName 接口名称，与微信文档的 analysis.xxx 一致
+var datacubeName = map[aims]string{
+	GetDailyRetain:       "getDailyRetain",
+	GetMonthlyRetain:     "getMonthlyRetain",
+	GetWeeklyRetain:      "getWeeklyRetain",
+	GetDailySummary:      "getDailySummary",
+	GetDailyVisitTrend:   "getDailyVisitTrend",
+	GetMonthlyVisitTrend: "getMonthlyVisitTrend",
+	GetWeeklyVisitTrend:  "getWeeklyVisitTrend",
+	GetUserPortrait:      "getUserPortrait",
+	GetVisitDistribution: "getVisitDistribution",
+	GetVisitPage:         "getVisitPage",
+}
+
+// String 接口名称，如 getDailyRetain
+func (a aims) String() string {
+	if name, ok := datacubeName[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("aims(%d)", int(a))
+}
+
+// ParseDatacube 按接口名称查找数据分析接口，不区分大小写，可省略 get 前缀，如 dailyRetain
+func ParseDatacube(name string) (aims, error) {
+	name = strings.ToLower(name)
+	for a := GetDailyRetain; a <= GetVisitPage; a++ {
+		if full := strings.ToLower(datacubeName[a]); name == full || "get"+name == full {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown datacube api %q", name)
+}
+
+// DatacubeNames 全部数据分析接口名称
+func DatacubeNames() []string {
+	names := make([]string, 0, len(datacubeName))
+	for a := GetDailyRetain; a <= GetVisitPage; a++ {
+		names = append(names, datacubeName[a])
+	}
+	return names
 }
 
 // DatacubeURL 数据分析URL
 func DatacubeURL(a aims) string {
-	return datacubeURL[a]
+	return DefaultBaseURL + datacubePath[a]
 }
 
 // GetDatacube 数据分析
 func GetDatacube(aims aims, accessToken string, request *GetDatacubeRequest, response interface{}) error {
+	return DefaultClient.GetDatacube(aims, accessToken, request, response)
+}
 
-	if err := validation.Validate(accessToken, validation.Empty); err != nil {
-		return errors.Wrap(err, "request param error")
+// GetDatacubeContext 同 GetDatacube，ctx 用于取消请求或设置超时
+func GetDatacubeContext(ctx context.Context, aims aims, accessToken string, request *GetDatacubeRequest, response interface{}) error {
+	return DefaultClient.GetDatacubeContext(ctx, aims, accessToken, request, response)
+}
+
+// GetDatacube 数据分析，accessToken 为空时由凭证管理器提供
+func (c *Client) GetDatacube(aims aims, accessToken string, request *GetDatacubeRequest, response interface{}) error {
+	return c.GetDatacubeContext(context.Background(), aims, accessToken, request, response)
+}
+
+// GetDatacubeContext 同 GetDatacube，ctx 用于取消请求或设置超时
+func (c *Client) GetDatacubeContext(ctx context.Context, aims aims, accessToken string, request *GetDatacubeRequest, response interface{}) error {
+
+	path, ok := datacubePath[aims]
+	if !ok {
+		return errors.Errorf("request param error: unknown datacube api %s", aims)
 	}
 
 	if err := validation.ValidateStruct(request,
-		validation.Field(&request.BeginDate, validation.Required),
-		validation.Field(&request.EndDate, validation.Required, validation.Min(request.BeginDate)),
+		validation.Field(&request.BeginDate, validation.Required, validation.Date(DatacubeDateLayout)),
+		validation.Field(&request.EndDate, validation.Required, validation.Date(DatacubeDateLayout)),
 	); err != nil {
 		return errors.Wrap(err, "request param error")
 	}
 
-	URL, err := encodeURL(DatacubeURL(aims), queryParams{"access_token": accessToken})
+	if err := validateDatacubeRange(aims, request); err != nil {
+		return errors.Wrap(err, "request param error")
+	}
+
+	URL, err := encodeURL(c.apiURL(path), queryParams{"access_token": accessToken})
 	if err != nil {
 		return errors.Wrap(err, "encode url error")
 	}
 
-	if err = httpPostJSON(DefaultHTTPClient, URL, request, response); err != nil {
+	if err = c.httpPostJSON(ctx, URL, request, response); err != nil {
 		return errors.Wrap(err, "http request error")
 	}
 
 	return nil
 }
 
+// GetDailyRetain 获取用户访问小程序日留存，accessToken 为空时由凭证管理器提供
+func (c *Client) GetDailyRetain(accessToken string, request *GetDatacubeRequest) (*GetDailyRetainResponse, error) {
+	return c.GetDailyRetainContext(context.Background(), accessToken, request)
+}
+
+// GetDailyRetainContext 同 GetDailyRetain，ctx 用于取消请求或设置超时
+func (c *Client) GetDailyRetainContext(ctx context.Context, accessToken string, request *GetDatacubeRequest) (*GetDailyRetainResponse, error) {
+	response := new(GetDailyRetainResponse)
+	if err := c.GetDatacubeContext(ctx, GetDailyRetain, accessToken, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetMonthlyRetain 获取用户访问小程序月留存，accessToken 为空时由凭证管理器提供
+func (c *Client) GetMonthlyRetain(accessToken string, request *GetDatacubeRequest) (*GetMonthlyRetainResponse, error) {
+	return c.GetMonthlyRetainContext(context.Background(), accessToken, request)
+}
+
+// GetMonthlyRetainContext 同 GetMonthlyRetain，ctx 用于取消请求或设置超时
+func (c *Client) GetMonthlyRetainContext(ctx context.Context, accessToken string, request *GetDatacubeRequest) (*GetMonthlyRetainResponse, error) {
+	response := new(GetMonthlyRetainResponse)
+	if err := c.GetDatacubeContext(ctx, GetMonthlyRetain, accessToken, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetWeeklyRetain 获取用户访问小程序周留存，accessToken 为空时由凭证管理器提供
+func (c *Client) GetWeeklyRetain(accessToken string, request *GetDatacubeRequest) (*GetWeeklyRetainResponse, error) {
+	return c.GetWeeklyRetainContext(context.Background(), accessToken, request)
+}
+
+// GetWeeklyRetainContext 同 GetWeeklyRetain，ctx 用于取消请求或设置超时
+func (c *Client) GetWeeklyRetainContext(ctx context.Context, accessToken string, request *GetDatacubeRequest) (*GetWeeklyRetainResponse, error) {
+	response := new(GetWeeklyRetainResponse)
+	if err := c.GetDatacubeContext(ctx, GetWeeklyRetain, accessToken, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetDailySummary 获取用户访问小程序数据概况，accessToken 为空时由凭证管理器提供
+func (c *Client) GetDailySummary(accessToken string, request *GetDatacubeRequest) (*GetDailySummaryResponse, error) {
+	return c.GetDailySummaryContext(context.Background(), accessToken, request)
+}
+
+// GetDailySummaryContext 同 GetDailySummary，ctx 用于取消请求或设置超时
+func (c *Client) GetDailySummaryContext(ctx context.Context, accessToken string, request *GetDatacubeRequest) (*GetDailySummaryResponse, error) {
+	response := new(GetDailySummaryResponse)
+	if err := c.GetDatacubeContext(ctx, GetDailySummary, accessToken, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetDailyVisitTrend 获取用户访问小程序数据日趋势，accessToken 为空时由凭证管理器提供
+func (c *Client) GetDailyVisitTrend(accessToken string, request *GetDatacubeRequest) (*GetDailyVisitTrendResponse, error) {
+	return c.GetDailyVisitTrendContext(context.Background(), accessToken, request)
+}
+
+// GetDailyVisitTrendContext 同 GetDailyVisitTrend，ctx 用于取消请求或设置超时
+func (c *Client) GetDailyVisitTrendContext(ctx context.Context, accessToken string, request *GetDatacubeRequest) (*GetDailyVisitTrendResponse, error) {
+	response := new(GetDailyVisitTrendResponse)
+	if err := c.GetDatacubeContext(ctx, GetDailyVisitTrend, accessToken, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetMonthlyVisitTrend 获取用户访问小程序数据月趋势，accessToken 为空时由凭证管理器提供
+func (c *Client) GetMonthlyVisitTrend(accessToken string, request *GetDatacubeRequest) (*GetMonthlyVisitTrendResponse, error) {
+	return c.GetMonthlyVisitTrendContext(context.Background(), accessToken, request)
+}
+
+// GetMonthlyVisitTrendContext 同 GetMonthlyVisitTrend，ctx 用于取消请求或设置超时
+func (c *Client) GetMonthlyVisitTrendContext(ctx context.Context, accessToken string, request *GetDatacubeRequest) (*GetMonthlyVisitTrendResponse, error) {
+	response := new(GetMonthlyVisitTrendResponse)
+	if err := c.GetDatacubeContext(ctx, GetMonthlyVisitTrend, accessToken, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetWeeklyVisitTrend 获取用户访问小程序数据周趋势，accessToken 为空时由凭证管理器提供
+func (c *Client) GetWeeklyVisitTrend(accessToken string, request *GetDatacubeRequest) (*GetWeeklyVisitTrendResponse, error) {
+	return c.GetWeeklyVisitTrendContext(context.Background(), accessToken, request)
+}
+
+// GetWeeklyVisitTrendContext 同 GetWeeklyVisitTrend，ctx 用于取消请求或设置超时
+func (c *Client) GetWeeklyVisitTrendContext(ctx context.Context, accessToken string, request *GetDatacubeRequest) (*GetWeeklyVisitTrendResponse, error) {
+	response := new(GetWeeklyVisitTrendResponse)
+	if err := c.GetDatacubeContext(ctx, GetWeeklyVisitTrend, accessToken, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetUserPortrait 获取小程序新增或活跃用户的画像分布数据，accessToken 为空时由凭证管理器提供
+func (c *Client) GetUserPortrait(accessToken string, request *GetDatacubeRequest) (*GetUserPortraitResponse, error) {
+	return c.GetUserPortraitContext(context.Background(), accessToken, request)
+}
+
+// GetUserPortraitContext 同 GetUserPortrait，ctx 用于取消请求或设置超时
+func (c *Client) GetUserPortraitContext(ctx context.Context, accessToken string, request *GetDatacubeRequest) (*GetUserPortraitResponse, error) {
+	response := new(GetUserPortraitResponse)
+	if err := c.GetDatacubeContext(ctx, GetUserPortrait, accessToken, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetVisitDistribution 获取用户小程序访问分布数据，accessToken 为空时由凭证管理器提供
+func (c *Client) GetVisitDistribution(accessToken string, request *GetDatacubeRequest) (*GetVisitDistributionResponse, error) {
+	return c.GetVisitDistributionContext(context.Background(), accessToken, request)
+}
+
+// GetVisitDistributionContext 同 GetVisitDistribution，ctx 用于取消请求或设置超时
+func (c *Client) GetVisitDistributionContext(ctx context.Context, accessToken string, request *GetDatacubeRequest) (*GetVisitDistributionResponse, error) {
+	response := new(GetVisitDistributionResponse)
+	if err := c.GetDatacubeContext(ctx, GetVisitDistribution, accessToken, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetVisitPage 访问页面，accessToken 为空时由凭证管理器提供
+func (c *Client) GetVisitPage(accessToken string, request *GetDatacubeRequest) (*GetVisitPageResponse, error) {
+	return c.GetVisitPageContext(context.Background(), accessToken, request)
+}
+
+// GetVisitPageContext 同 GetVisitPage，ctx 用于取消请求或设置超时
+func (c *Client) GetVisitPageContext(ctx context.Context, accessToken string, request *GetDatacubeRequest) (*GetVisitPageResponse, error) {
+	response := new(GetVisitPageResponse)
+	if err := c.GetDatacubeContext(ctx, GetVisitPage, accessToken, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
 // GetDatacubeRequest 请求
 type GetDatacubeRequest struct {
 	BeginDate string `json:"begin_date"` //开始日期。格式为 yyyymmdd
@@ -177,11 +383,16 @@ type DailyVisitTrend struct {
 	VisitDepth      float64 `json:"visit_depth"`       //平均访问深度 (浮点型)
 }
 
-// GetMonthlyVisitTrendRespone 获取用户访问小程序数据月趋势-响应
-type GetMonthlyVisitTrendRespone struct {
+// GetMonthlyVisitTrendResponse 获取用户访问小程序数据月趋势-响应
+type GetMonthlyVisitTrendResponse struct {
 	List []MonthlyVisitTrend `json:"list"`
 }
 
+// GetMonthlyVisitTrendRespone 获取用户访问小程序数据月趋势-响应
+//
+// Deprecated: 拼写错误，请使用 GetMonthlyVisitTrendResponse
+type GetMonthlyVisitTrendRespone = GetMonthlyVisitTrendResponse
+
 // MonthlyVisitTrend 用户访问小程序数据月趋势
 type MonthlyVisitTrend struct {
 	RefDate         string  `json:"ref_date"`          //时间，格式为 yyyymm，如："201702"
@@ -193,3 +404,198 @@ type MonthlyVisitTrend struct {
 	StayTimeSession float64 `json:"stay_time_session"` //次均停留时长 (浮点型，单位：秒)
 	VisitDepth      float64 `json:"visit_depth"`       //平均访问深度 (浮点型)
 }
+
+// GetWeeklyVisitTrendResponse 获取用户访问小程序数据周趋势-响应
+type GetWeeklyVisitTrendResponse struct {
+	List []WeeklyVisitTrend `json:"list"`
+}
+
+// WeeklyVisitTrend 用户访问小程序数据周趋势
+type WeeklyVisitTrend struct {
+	RefDate         string  `json:"ref_date"`          //时间，格式为 yyyymmdd-yyyymmdd，如："20170306-20170312"
+	SessionCnt      int     `json:"session_cnt"`       //打开次数（自然周内汇总）
+	VisitPV         int     `json:"visit_pv"`          //访问次数（自然周内汇总）
+	VisitUV         int     `json:"visit_uv"`          //访问人数（自然周内去重）
+	VisitUvNew      int     `json:"visit_uv_new"`      //新用户数（自然周内去重）
+	StayTimeUV      float64 `json:"stay_time_uv"`      //人均停留时长 (浮点型，单位：秒)
+	StayTimeSession float64 `json:"stay_time_session"` //次均停留时长 (浮点型，单位：秒)
+	VisitDepth      float64 `json:"visit_depth"`       //平均访问深度 (浮点型)
+}
+
+// GetUserPortraitResponse 获取小程序新增或活跃用户的画像分布数据-响应
+type GetUserPortraitResponse struct {
+	RefDate    string       `json:"ref_date"`     //时间范围，如："20170611-20170617"
+	VisitUvNew UserPortrait `json:"visit_uv_new"` //新用户画像
+	VisitUv    UserPortrait `json:"visit_uv"`     //活跃用户画像
+}
+
+// UserPortrait 用户画像
+type UserPortrait struct {
+	Province  []PortraitItem `json:"province"`  //省份，如北京、广东等
+	City      []PortraitItem `json:"city"`      //城市，如北京、广州等
+	Genders   []PortraitItem `json:"genders"`   //性别，包括男、女、未知
+	Platforms []PortraitItem `json:"platforms"` //终端类型，包括 iPhone，android，其他
+	Devices   []PortraitItem `json:"devices"`   //机型，如苹果 iPhone 6，OPPO R9 等
+	Ages      []PortraitItem `json:"ages"`      //年龄，包括17岁以下、18-24岁等区间
+}
+
+// PortraitItem 画像分布项
+type PortraitItem struct {
+	ID    int    `json:"id"`    //属性值id，机型无此字段
+	Name  string `json:"name"`  //属性值名称，与id对应。如属性为 province 时，返回的属性值名称包括「广东」等
+	Value int    `json:"value"` //该场景访问uv
+}
+
+// 画像终端类型 id
+const (
+	PlatformIPhone  = 1 // iPhone
+	PlatformAndroid = 2 // android
+	PlatformOther   = 3 // 其他
+)
+
+// 画像性别 id
+const (
+	GenderUnknown = 0 // 未知
+	GenderMale    = 1 // 男
+	GenderFemale  = 2 // 女
+)
+
+// 画像年龄 id
+const (
+	AgeUnknown = 0 // 未知
+	AgeUnder17 = 1 // 17岁以下
+	Age18To24  = 2 // 18-24岁
+	Age25To29  = 3 // 25-29岁
+	Age30To39  = 4 // 30-39岁
+	Age40To49  = 5 // 40-49岁
+	AgeOver50  = 6 // 50岁以上
+)
+
+// GetVisitDistributionResponse 获取用户小程序访问分布数据-响应
+type GetVisitDistributionResponse struct {
+	RefDate string              `json:"ref_date"` //日期，格式为 yyyymmdd
+	List    []VisitDistribution `json:"list"`     //数据列表
+}
+
+// Distribution 查找指定分布类型的数据，不存在时返回 nil
+func (r *GetVisitDistributionResponse) Distribution(index string) []VisitDistributionItem {
+	for i := range r.List {
+		if r.List[i].Index == index {
+			return r.List[i].ItemList
+		}
+	}
+	return nil
+}
+
+// 访问分布类型
+const (
+	DistributionAccessSource = "access_source_session_cnt" // 访问来源分布，key 为场景 id，value 为访问 pv
+	DistributionStayTime     = "access_staytime_info"      // 访问时长分布，key 为时长区间 id，value 为访问次数
+	DistributionDepth        = "access_depth_info"         // 访问深度分布，key 为深度区间 id，value 为访问次数
+)
+
+// accessSourceNames 访问来源场景 id
+var accessSourceNames = map[int]string{
+	1:  "小程序历史列表",
+	2:  "搜索",
+	3:  "会话",
+	4:  "扫一扫二维码",
+	5:  "公众号主页",
+	6:  "聊天顶部",
+	7:  "系统桌面",
+	8:  "小程序主页",
+	9:  "附近的小程序",
+	10: "其他",
+	11: "模板消息",
+	12: "客服消息",
+	13: "公众号菜单",
+	14: "APP分享",
+	15: "支付完成页",
+	16: "长按识别二维码",
+	17: "相册选取二维码",
+	18: "公众号文章",
+	19: "钱包",
+	20: "卡包",
+	21: "小程序内卡券",
+	22: "其他小程序",
+	23: "其他小程序返回",
+	24: "卡券适用门店列表",
+	25: "搜索框快捷入口",
+	26: "小程序客服消息",
+	27: "公众号下发",
+	28: "系统会话菜单",
+	29: "任务栏-最近使用",
+	30: "长按小程序菜单圆点",
+	31: "连wifi成功页",
+	32: "城市服务",
+	33: "微信广告",
+	34: "其他移动应用",
+	35: "发现入口-我的小程序",
+	36: "任务栏-我的小程序",
+}
+
+// stayTimeNames 访问时长区间 id
+var stayTimeNames = map[int]string{
+	1: "0-2s",
+	2: "3-5s",
+	3: "6-10s",
+	4: "11-20s",
+	5: "20-30s",
+	6: "30-50s",
+	7: "50-100s",
+	8: ">100s",
+}
+
+// depthNames 访问深度区间 id
+var depthNames = map[int]string{
+	1: "1页",
+	2: "2页",
+	3: "3页",
+	4: "4页",
+	5: "5页",
+	6: "6-10页",
+	7: ">10页",
+}
+
+// DistributionKeyName 分布数据 key 的含义，未知的分布类型或 key 返回空字符串
+func DistributionKeyName(index string, key int) string {
+	switch index {
+	case DistributionAccessSource:
+		return accessSourceNames[key]
+	case DistributionStayTime:
+		return stayTimeNames[key]
+	case DistributionDepth:
+		return depthNames[key]
+	}
+	return ""
+}
+
+// VisitDistribution 分布类型的数据
+type VisitDistribution struct {
+	Index    string                  `json:"index"`     //分布类型，见 DistributionAccessSource 等常量
+	ItemList []VisitDistributionItem `json:"item_list"` //分布数据列表
+}
+
+// VisitDistributionItem 分布数据
+type VisitDistributionItem struct {
+	Key   int `json:"key"`   //场景 id 或区间 id，含义见 DistributionKeyName
+	Value int `json:"value"` //该场景 id 访问 pv 或该区间内的访问次数
+}
+
+// GetVisitPageResponse 访问页面-响应
+type GetVisitPageResponse struct {
+	RefDate string      `json:"ref_date"` //日期，格式为 yyyymmdd
+	List    []VisitPage `json:"list"`     //数据列表
+}
+
+// VisitPage 页面访问数据
+type VisitPage struct {
+	PagePath       string  `json:"page_path"`        //页面路径
+	PageVisitPV    int     `json:"page_visit_pv"`    //访问次数
+	PageVisitUV    int     `json:"page_visit_uv"`    //访问人数
+	PageStaytimePV float64 `json:"page_staytime_pv"` //次均停留时长
+	EntrypagePV    int     `json:"entrypage_pv"`     //进入页次数
+	ExitpagePV     int     `json:"exitpage_pv"`      //退出页次数
+	PageSharePV    int     `json:"page_share_pv"`    //转发次数
+	PageShareUV    int     `json:"page_share_uv"`    //转发人数
+}
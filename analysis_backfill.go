@@ -0,0 +1,170 @@
+package wechat
+
+import (
+	"context"
+	"time"
+)
+
+// SplitDatacubeRange 将任意日期范围拆分为接口支持的查询窗口：单日接口与用户画像按天，
+// 周接口按覆盖到的自然周，月接口按覆盖到的自然月
+func SplitDatacubeRange(aims aims, begin, end time.Time) []*GetDatacubeRequest {
+
+	first, last := date(begin), date(end)
+	if last.Before(first) {
+		return nil
+	}
+
+	var windows []*GetDatacubeRequest
+	switch datacubePeriod[aims] {
+	case periodWeekly:
+		for d := first.AddDate(0, 0, -(int(first.Weekday())+6)%7); !d.After(last); d = d.AddDate(0, 0, 7) {
+			windows = append(windows, NewWeeklyRange(d))
+		}
+	case periodMonthly:
+		for d := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC); !d.After(last); d = d.AddDate(0, 1, 0) {
+			windows = append(windows, NewMonthlyRange(d))
+		}
+	default:
+		for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+			windows = append(windows, NewDailyRange(d))
+		}
+	}
+	return windows
+}
+
+// newDatacubeResponse 创建与接口对应的响应
+func newDatacubeResponse(aims aims) interface{} {
+	switch aims {
+	case GetDailyRetain:
+		return new(GetDailyRetainResponse)
+	case GetMonthlyRetain:
+		return new(GetMonthlyRetainResponse)
+	case GetWeeklyRetain:
+		return new(GetWeeklyRetainResponse)
+	case GetDailySummary:
+		return new(GetDailySummaryResponse)
+	case GetDailyVisitTrend:
+		return new(GetDailyVisitTrendResponse)
+	case GetMonthlyVisitTrend:
+		return new(GetMonthlyVisitTrendResponse)
+	case GetWeeklyVisitTrend:
+		return new(GetWeeklyVisitTrendResponse)
+	case GetUserPortrait:
+		return new(GetUserPortraitResponse)
+	case GetVisitDistribution:
+		return new(GetVisitDistributionResponse)
+	case GetVisitPage:
+		return new(GetVisitPageResponse)
+	}
+	return nil
+}
+
+// BackfillResult 一个查询窗口的结果
+type BackfillResult struct {
+	Request  *GetDatacubeRequest // 查询窗口
+	Response interface{}         // 与接口对应的响应，如 GetDailyVisitTrend 为 *GetDailyVisitTrendResponse，失败时为 nil
+	Err      error               // 该窗口的错误，不影响其他窗口
+}
+
+// backfillOptions 回填配置
+type backfillOptions struct {
+	concurrency int
+	interval    time.Duration
+}
+
+// BackfillOption 回填配置项
+type BackfillOption func(*backfillOptions)
+
+// WithBackfillConcurrency 设置最大并发请求数，默认 4
+func WithBackfillConcurrency(n int) BackfillOption {
+	return func(o *backfillOptions) {
+		if n > 0 {
+			o.concurrency = n
+		}
+	}
+}
+
+// WithBackfillInterval 设置相邻请求发出的最小间隔，默认不限制；客户端设置的 RateLimiter 同样生效
+func WithBackfillInterval(d time.Duration) BackfillOption {
+	return func(o *backfillOptions) {
+		o.interval = d
+	}
+}
+
+// Backfill 拉取 begin 至 end 的历史数据，按 SplitDatacubeRange 拆分窗口后并发请求，
+// 结果按窗口顺序写入返回的 channel，每个窗口恰好一个结果，全部完成后关闭。
+// ctx 取消后已完成的窗口照常返回，未完成的窗口以 ctx 的错误返回；channel 容量为窗口数，调用方中途停止读取不会阻塞
+func (c *Client) Backfill(ctx context.Context, aims aims, accessToken string, begin, end time.Time, opts ...BackfillOption) <-chan BackfillResult {
+
+	o := backfillOptions{concurrency: 4}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	windows := SplitDatacubeRange(aims, begin, end)
+	slots := make([]chan BackfillResult, len(windows))
+	for i := range slots {
+		slots[i] = make(chan BackfillResult, 1)
+	}
+
+	go func() {
+		sem := make(chan struct{}, o.concurrency)
+		var tick <-chan time.Time
+		if o.interval > 0 {
+			ticker := time.NewTicker(o.interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for i, window := range windows {
+			if err := backfillWait(ctx, sem, tick, i == 0); err != nil {
+				for j := i; j < len(windows); j++ {
+					slots[j] <- BackfillResult{Request: windows[j], Err: err}
+				}
+				return
+			}
+
+			go func(slot chan<- BackfillResult, window *GetDatacubeRequest) {
+				defer func() { <-sem }()
+
+				response := newDatacubeResponse(aims)
+				if err := c.GetDatacubeContext(ctx, aims, accessToken, window, response); err != nil {
+					slot <- BackfillResult{Request: window, Err: err}
+					return
+				}
+				slot <- BackfillResult{Request: window, Response: response}
+			}(slots[i], window)
+		}
+	}()
+
+	results := make(chan BackfillResult, len(windows))
+	go func() {
+		defer close(results)
+		for _, slot := range slots {
+			results <- <-slot
+		}
+	}()
+
+	return results
+}
+
+// backfillWait 等待并发名额与请求间隔
+func backfillWait(ctx context.Context, sem chan struct{}, tick <-chan time.Time, first bool) error {
+
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if tick == nil || first {
+		return nil
+	}
+	select {
+	case <-tick:
+		return nil
+	case <-ctx.Done():
+		<-sem
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,166 @@
+package wechat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestSplitDatacubeRange(t *testing.T) {
+
+	begin := time.Date(2017, 1, 30, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2017, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		aims  aims
+		count int
+		first string
+		last  string
+	}{
+		{GetDailyVisitTrend, 32, "20170130-20170130", "20170302-20170302"},
+		{GetWeeklyRetain, 5, "20170130-20170205", "20170227-20170305"},
+		{GetMonthlyVisitTrend, 3, "20170101-20170131", "20170301-20170331"},
+		{GetUserPortrait, 32, "20170130-20170130", "20170302-20170302"},
+	}
+
+	for _, tt := range tests {
+		windows := SplitDatacubeRange(tt.aims, begin, end)
+		if len(windows) != tt.count {
+			t.Fatalf("%s: %d windows, want %d", datacubePath[tt.aims], len(windows), tt.count)
+		}
+		first, last := windows[0], windows[len(windows)-1]
+		if got := first.BeginDate + "-" + first.EndDate; got != tt.first {
+			t.Errorf("%s: first %s, want %s", datacubePath[tt.aims], got, tt.first)
+		}
+		if got := last.BeginDate + "-" + last.EndDate; got != tt.last {
+			t.Errorf("%s: last %s, want %s", datacubePath[tt.aims], got, tt.last)
+		}
+		for _, w := range windows {
+			if err := validateDatacubeRange(tt.aims, w); err != nil {
+				t.Errorf("%s: invalid window %+v: %v", datacubePath[tt.aims], w, err)
+			}
+		}
+	}
+
+	if windows := SplitDatacubeRange(GetDailyRetain, end, begin); windows != nil {
+		t.Errorf("reversed range: %v", windows)
+	}
+}
+
+func TestBackfill(t *testing.T) {
+
+	var inflight, maxInflight int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInflight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInflight, max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		var req GetDatacubeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.BeginDate == "20170305" {
+			fmt.Fprint(w, `{"errcode":61500,"errmsg":"date format error"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"list":[{"ref_date":%q,"visit_pv":1}]}`, req.BeginDate)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL))
+
+	begin := time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2017, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	var got []string
+	for result := range client.Backfill(context.Background(), GetDailyVisitTrend, "token", begin, end, WithBackfillConcurrency(3)) {
+		if result.Request.BeginDate == "20170305" {
+			if result.Err == nil {
+				t.Errorf("want error for %s", result.Request.BeginDate)
+			}
+			got = append(got, "error")
+			continue
+		}
+		if result.Err != nil {
+			t.Fatalf("%s: %v", result.Request.BeginDate, result.Err)
+		}
+		got = append(got, result.Response.(*GetDailyVisitTrendResponse).List[0].RefDate)
+	}
+
+	want := []string{"20170301", "20170302", "20170303", "20170304", "error", "20170306", "20170307", "20170308", "20170309", "20170310"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if max := atomic.LoadInt32(&maxInflight); max > 3 {
+		t.Fatalf("max inflight %d exceeds concurrency", max)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for result := range client.Backfill(ctx, GetDailyVisitTrend, "token", begin, end) {
+		if result.Err == nil {
+			t.Fatalf("want canceled error")
+		}
+	}
+}
+
+func TestBackfillCanceled(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req GetDatacubeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.BeginDate > "20170302" {
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprintf(w, `{"list":[{"ref_date":%q,"visit_pv":1}]}`, req.BeginDate)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL))
+
+	begin := time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2017, 3, 10, 0, 0, 0, 0, time.UTC)
+	windows := SplitDatacubeRange(GetDailyVisitTrend, begin, end)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var got []BackfillResult
+	for result := range client.Backfill(ctx, GetDailyVisitTrend, "token", begin, end, WithBackfillConcurrency(2)) {
+		got = append(got, result)
+		if len(got) == 2 {
+			cancel()
+		}
+	}
+
+	if len(got) != len(windows) {
+		t.Fatalf("got %d results, want %d", len(got), len(windows))
+	}
+	for i, result := range got {
+		if result.Request.BeginDate != windows[i].BeginDate {
+			t.Fatalf("result %d: %s, want %s", i, result.Request.BeginDate, windows[i].BeginDate)
+		}
+		if (result.Response == nil) == (result.Err == nil) {
+			t.Fatalf("result %d: response %v, err %v", i, result.Response, result.Err)
+		}
+		if i < 2 && result.Err != nil {
+			t.Fatalf("completed window %s dropped: %v", result.Request.BeginDate, result.Err)
+		}
+		if i >= 2 && !errors.Is(result.Err, context.Canceled) {
+			t.Fatalf("window %s: %v", result.Request.BeginDate, result.Err)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package wechat
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// CohortUser 留存的用户类型
+type CohortUser string
+
+const (
+	CohortNew    CohortUser = "visit_uv_new" // 新增用户留存
+	CohortActive CohortUser = "visit_uv"     // 活跃用户留存
+)
+
+// CohortRow 一个 ref_date 的留存，Values 的键为留存数据的 key
+type CohortRow struct {
+	RefDate string
+	Values  map[int]int // key=0 为当期用户数，key>0 为留存用户数
+}
+
+// Size 当期用户数，即 key=0 的值
+func (r CohortRow) Size() int {
+	return r.Values[0]
+}
+
+// Rate 第 key 期的留存率，即 key 对应值除以 key=0 的值；缺少数据或当期用户数为 0 时 ok 为 false
+func (r CohortRow) Rate(key int) (rate float64, ok bool) {
+	size, ok := r.Values[0]
+	if !ok || size == 0 {
+		return 0, false
+	}
+	value, ok := r.Values[key]
+	if !ok {
+		return 0, false
+	}
+	return float64(value) / float64(size), true
+}
+
+// RetentionCohort 留存矩阵，由多个 ref_date 的同类型留存数据组成，行按 ref_date 升序
+type RetentionCohort struct {
+	Unit   string // 留存周期，day、week 或 month
+	Keys   []int  // 留存数据的 key，如日留存为 0,1,2,3,4,5,6,7,14,30
+	New    []CohortRow
+	Active []CohortRow
+}
+
+// NewRetentionCohort 由日、周或月留存响应（值或指针）组成留存矩阵，响应类型需一致，ref_date 重复时以后者为准
+func NewRetentionCohort(responses ...interface{}) (*RetentionCohort, error) {
+
+	var cohort *RetentionCohort
+	rows := make(map[string][2]CohortRow)
+
+	for _, response := range responses {
+		var (
+			unit    string
+			keys    []int
+			refDate string
+			uvNew   interface{}
+			uv      interface{}
+		)
+		switch r := response.(type) {
+		case *GetDailyRetainResponse:
+			unit, keys, refDate, uvNew, uv = "day", dailyRetainKeys, r.RefDate, r.VisitUvNew, r.VisitUv
+		case GetDailyRetainResponse:
+			unit, keys, refDate, uvNew, uv = "day", dailyRetainKeys, r.RefDate, r.VisitUvNew, r.VisitUv
+		case *GetWeeklyRetainResponse:
+			unit, keys, refDate, uvNew, uv = "week", weeklyRetainKeys, r.RefDate, r.VisitUvNew, r.VisitUv
+		case GetWeeklyRetainResponse:
+			unit, keys, refDate, uvNew, uv = "week", weeklyRetainKeys, r.RefDate, r.VisitUvNew, r.VisitUv
+		case *GetMonthlyRetainResponse:
+			unit, keys, refDate, uvNew, uv = "month", monthlyRetainKeys, r.RefDate, r.VisitUvNew, r.VisitUv
+		case GetMonthlyRetainResponse:
+			unit, keys, refDate, uvNew, uv = "month", monthlyRetainKeys, r.RefDate, r.VisitUvNew, r.VisitUv
+		default:
+			return nil, fmt.Errorf("unsupported retain response type %T", response)
+		}
+
+		if cohort == nil {
+			cohort = &RetentionCohort{Unit: unit, Keys: keys}
+		} else if cohort.Unit != unit {
+			return nil, errors.New("retain responses must be of the same type")
+		}
+		rows[refDate] = [2]CohortRow{
+			{RefDate: refDate, Values: retainValues(uvNew)},
+			{RefDate: refDate, Values: retainValues(uv)},
+		}
+	}
+	if cohort == nil {
+		return nil, errors.New("no retain response")
+	}
+
+	refDates := make([]string, 0, len(rows))
+	for refDate := range rows {
+		refDates = append(refDates, refDate)
+	}
+	sort.Strings(refDates)
+	for _, refDate := range refDates {
+		cohort.New = append(cohort.New, rows[refDate][0])
+		cohort.Active = append(cohort.Active, rows[refDate][1])
+	}
+
+	return cohort, nil
+}
+
+// Rows 指定用户类型的各行
+func (c *RetentionCohort) Rows(user CohortUser) []CohortRow {
+	if user == CohortNew {
+		return c.New
+	}
+	return c.Active
+}
+
+// AverageRate 第 key 期的平均留存率，按各行当期用户数加权，即留存用户数之和除以当期用户数之和；
+// 只统计已有第 key 期数据的行，没有这样的行时 ok 为 false
+func (c *RetentionCohort) AverageRate(user CohortUser, key int) (rate float64, ok bool) {
+
+	var retained, size int
+	for _, row := range c.Rows(user) {
+		value, has := row.Values[key]
+		if !has || row.Size() == 0 {
+			continue
+		}
+		retained += value
+		size += row.Size()
+	}
+	if size == 0 {
+		return 0, false
+	}
+	return float64(retained) / float64(size), true
+}
+
+// Table 留存矩阵表格，列为 ref_date、users 及每期留存，如 day_1；
+// rates 为 true 时留存列为留存率（保留 4 位小数），否则为留存用户数，缺失为 nil
+func (c *RetentionCohort) Table(user CohortUser, rates bool) *DatacubeTable {
+
+	table := &DatacubeTable{Header: []string{"ref_date", "users"}}
+	for _, key := range c.Keys {
+		if key > 0 {
+			table.Header = append(table.Header, fmt.Sprintf("%s_%d", c.Unit, key))
+		}
+	}
+
+	for _, row := range c.Rows(user) {
+		var size interface{}
+		if value, ok := row.Values[0]; ok {
+			size = value
+		}
+		cells := []interface{}{row.RefDate, size}
+		for _, key := range c.Keys {
+			if key == 0 {
+				continue
+			}
+			var cell interface{}
+			if rates {
+				if rate, ok := row.Rate(key); ok {
+					cell = math.Round(rate*10000) / 10000
+				}
+			} else if value, ok := row.Values[key]; ok {
+				cell = value
+			}
+			cells = append(cells, cell)
+		}
+		table.Rows = append(table.Rows, cells)
+	}
+
+	return table
+}
@@ -0,0 +1,73 @@
+package wechat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRetentionCohort(t *testing.T) {
+
+	cohort, err := NewRetentionCohort(
+		&GetWeeklyRetainResponse{
+			RefDate:    "20170313-20170319",
+			VisitUvNew: []WeeklyRetainVisitUvNew{{Key: 0, Value: 200}, {Key: 1, Value: 50}},
+			VisitUv:    []WeeklyRetainVisitUv{{Key: 0, Value: 1000}},
+		},
+		GetWeeklyRetainResponse{
+			RefDate:    "20170306-20170312",
+			VisitUvNew: []WeeklyRetainVisitUvNew{{Key: 0, Value: 100}, {Key: 1, Value: 30}, {Key: 2, Value: 20}},
+			VisitUv:    []WeeklyRetainVisitUv{{Key: 0, Value: 0}, {Key: 1, Value: 5}},
+		},
+	)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if cohort.Unit != "week" || len(cohort.New) != 2 || cohort.New[0].RefDate != "20170306-20170312" {
+		t.Fatalf("cohort: %+v", cohort)
+	}
+
+	if rate, ok := cohort.New[0].Rate(1); !ok || rate != 0.3 {
+		t.Fatalf("rate: %v %v", rate, ok)
+	}
+	if _, ok := cohort.Active[0].Rate(1); ok {
+		t.Fatal("rate with empty cohort should not be ok")
+	}
+	if rate, ok := cohort.AverageRate(CohortNew, 1); !ok || rate != 80.0/300 {
+		t.Fatalf("average rate: %v %v", rate, ok)
+	}
+	if rate, ok := cohort.AverageRate(CohortNew, 2); !ok || rate != 0.2 {
+		t.Fatalf("average rate: %v %v", rate, ok)
+	}
+	if _, ok := cohort.AverageRate(CohortActive, 1); ok {
+		t.Fatal("average rate without data should not be ok")
+	}
+
+	var buf bytes.Buffer
+	if err = cohort.Table(CohortNew, true).WriteCSV(&buf); err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := "ref_date,users,week_1,week_2,week_3,week_4\n" +
+		"20170306-20170312,100,0.3,0.2,,\n" +
+		"20170313-20170319,200,0.25,,,\n"
+	if buf.String() != want {
+		t.Fatalf("csv:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err = cohort.Table(CohortActive, false).WriteText(&buf); err != nil {
+		t.Fatalf("%v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if lines[0] != "ref_date           users  week_1  week_2  week_3  week_4" ||
+		strings.Join(strings.Fields(lines[1]), " ") != "20170306-20170312 0 5" {
+		t.Fatalf("text:\n%s", buf.String())
+	}
+
+	if _, err = NewRetentionCohort(&GetDailyRetainResponse{}, &GetMonthlyRetainResponse{}); err == nil {
+		t.Fatal("mixed retain types should fail")
+	}
+	if _, err = NewRetentionCohort(&GetDailySummaryResponse{}); err == nil {
+		t.Fatal("non retain response should fail")
+	}
+}
@@ -0,0 +1,341 @@
+package wechat
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+)
+
+// 留存数据的 key 取值，导出时每个 key 一列，保证表头稳定
+var (
+	dailyRetainKeys   = []int{0, 1, 2, 3, 4, 5, 6, 7, 14, 30}
+	weeklyRetainKeys  = []int{0, 1, 2, 3, 4}
+	monthlyRetainKeys = []int{0, 1}
+)
+
+// DatacubeTable 扁平化后的数据分析结果，首列为 ref_date
+type DatacubeTable struct {
+	Header []string
+	Rows   [][]interface{} // 值为 string、int、float64，缺失为 nil
+}
+
+// FlattenDatacube 将数据分析响应扁平化为表格，支持全部 GetXxxResponse 类型（值或指针）。
+// 留存数据出现列之外的 key 时返回错误，避免数据被静默丢弃
+func FlattenDatacube(response interface{}) (*DatacubeTable, error) {
+
+	if v := reflect.ValueOf(response); v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, fmt.Errorf("nil datacube response %T", response)
+	}
+
+	switch r := response.(type) {
+	case *GetDailyRetainResponse:
+		return flattenRetain(r.RefDate, "day", dailyRetainKeys, retainValues(r.VisitUvNew), retainValues(r.VisitUv))
+	case *GetWeeklyRetainResponse:
+		return flattenRetain(r.RefDate, "week", weeklyRetainKeys, retainValues(r.VisitUvNew), retainValues(r.VisitUv))
+	case *GetMonthlyRetainResponse:
+		return flattenRetain(r.RefDate, "month", monthlyRetainKeys, retainValues(r.VisitUvNew), retainValues(r.VisitUv))
+	case *GetDailySummaryResponse:
+		return flattenList(DailySummary{}, r.List, ""), nil
+	case *GetDailyVisitTrendResponse:
+		return flattenList(DailyVisitTrend{}, r.List, ""), nil
+	case *GetWeeklyVisitTrendResponse:
+		return flattenList(WeeklyVisitTrend{}, r.List, ""), nil
+	case *GetMonthlyVisitTrendResponse:
+		return flattenList(MonthlyVisitTrend{}, r.List, ""), nil
+	case *GetVisitPageResponse:
+		return flattenList(VisitPage{}, r.List, r.RefDate), nil
+	case *GetUserPortraitResponse:
+		return flattenPortrait(r), nil
+	case *GetVisitDistributionResponse:
+		return flattenDistribution(r), nil
+	}
+
+	// 值类型转为指针后处理
+	if v := reflect.ValueOf(response); v.IsValid() && v.Kind() == reflect.Struct {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		return FlattenDatacube(ptr.Interface())
+	}
+
+	return nil, fmt.Errorf("unsupported datacube response type %T", response)
+}
+
+// retainValues 留存数据转为 key => value，三种留存类型字段相同
+func retainValues(list interface{}) map[int]int {
+	values := make(map[int]int)
+	v := reflect.ValueOf(list)
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		values[int(item.FieldByName("Key").Int())] = int(item.FieldByName("Value").Int())
+	}
+	return values
+}
+
+// flattenRetain 留存数据一行，新增与活跃用户的每个 key 各一列，如 visit_uv_new_day_1；
+// 列固定以便多个窗口的结果合并，出现 keys 之外的 key 时返回错误
+func flattenRetain(refDate, unit string, keys []int, uvNew, uv map[int]int) (*DatacubeTable, error) {
+
+	table := &DatacubeTable{Header: []string{"ref_date"}}
+	row := []interface{}{refDate}
+
+	columns := make(map[int]bool, len(keys))
+	for _, key := range keys {
+		columns[key] = true
+	}
+
+	for _, group := range []struct {
+		name   string
+		values map[int]int
+	}{{"visit_uv_new", uvNew}, {"visit_uv", uv}} {
+		for key := range group.values {
+			if !columns[key] {
+				return nil, fmt.Errorf("%s: unexpected retain key %s_%s_%d", refDate, group.name, unit, key)
+			}
+		}
+		for _, key := range keys {
+			table.Header = append(table.Header, fmt.Sprintf("%s_%s_%d", group.name, unit, key))
+			if value, ok := group.values[key]; ok {
+				row = append(row, value)
+			} else {
+				row = append(row, nil)
+			}
+		}
+	}
+
+	table.Rows = append(table.Rows, row)
+	return table, nil
+}
+
+// flattenList 列表数据每项一行，列为结构体 json 字段；元素无 ref_date 时以 refDate 作为首列
+func flattenList(elem interface{}, list interface{}, refDate string) *DatacubeTable {
+
+	t := reflect.TypeOf(elem)
+	table := &DatacubeTable{}
+
+	hasRefDate := false
+	for i := 0; i < t.NumField(); i++ {
+		if jsonName(t.Field(i)) == "ref_date" {
+			hasRefDate = true
+		}
+	}
+	if !hasRefDate {
+		table.Header = append(table.Header, "ref_date")
+	}
+	for i := 0; i < t.NumField(); i++ {
+		table.Header = append(table.Header, jsonName(t.Field(i)))
+	}
+
+	v := reflect.ValueOf(list)
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		var row []interface{}
+		if !hasRefDate {
+			row = append(row, refDate)
+		}
+		for j := 0; j < item.NumField(); j++ {
+			row = append(row, item.Field(j).Interface())
+		}
+		table.Rows = append(table.Rows, row)
+	}
+
+	return table
+}
+
+// jsonName 字段的 json 名称
+func jsonName(field reflect.StructField) string {
+	return strings.Split(field.Tag.Get("json"), ",")[0]
+}
+
+// flattenPortrait 用户画像每个属性值一行
+func flattenPortrait(r *GetUserPortraitResponse) *DatacubeTable {
+
+	table := &DatacubeTable{Header: []string{"ref_date", "user_type", "dimension", "id", "name", "value"}}
+
+	for _, group := range []struct {
+		userType string
+		portrait UserPortrait
+	}{{"visit_uv_new", r.VisitUvNew}, {"visit_uv", r.VisitUv}} {
+		p := group.portrait
+		for _, dimension := range []struct {
+			name  string
+			items []PortraitItem
+		}{
+			{"province", p.Province},
+			{"city", p.City},
+			{"genders", p.Genders},
+			{"platforms", p.Platforms},
+			{"devices", p.Devices},
+			{"ages", p.Ages},
+		} {
+			for _, item := range dimension.items {
+				table.Rows = append(table.Rows, []interface{}{r.RefDate, group.userType, dimension.name, item.ID, item.Name, item.Value})
+			}
+		}
+	}
+
+	return table
+}
+
+// flattenDistribution 访问分布每个 key 一行，附带 key 的含义
+func flattenDistribution(r *GetVisitDistributionResponse) *DatacubeTable {
+
+	table := &DatacubeTable{Header: []string{"ref_date", "index", "key", "key_name", "value"}}
+	for _, distribution := range r.List {
+		for _, item := range distribution.ItemList {
+			table.Rows = append(table.Rows, []interface{}{
+				r.RefDate, distribution.Index, item.Key, DistributionKeyName(distribution.Index, item.Key), item.Value,
+			})
+		}
+	}
+	return table
+}
+
+// Append 追加同类型结果的行，表头不一致时返回错误
+func (t *DatacubeTable) Append(other *DatacubeTable) error {
+	if strings.Join(t.Header, ",") != strings.Join(other.Header, ",") {
+		return errors.New("datacube table header mismatch")
+	}
+	t.Rows = append(t.Rows, other.Rows...)
+	return nil
+}
+
+// WriteCSV 写入 CSV，首行为表头
+func (t *DatacubeTable) WriteCSV(w io.Writer) error {
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(t.Header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(t.Header))
+	for _, row := range t.Rows {
+		for i, value := range row {
+			record[i] = formatCell(value)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSONLines 写入 JSON Lines，每行一个对象，字段顺序与表头一致
+func (t *DatacubeTable) WriteJSONLines(w io.Writer) error {
+
+	buffer := textBufferPool.Get().(*bytes.Buffer)
+	defer textBufferPool.Put(buffer)
+
+	for _, row := range t.Rows {
+		buffer.Reset()
+		buffer.WriteByte('{')
+		for i, value := range row {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			key, err := json.Marshal(t.Header[i])
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			buffer.Write(key)
+			buffer.WriteByte(':')
+			buffer.Write(data)
+		}
+		buffer.WriteString("}\n")
+
+		if _, err := w.Write(buffer.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteText 写入对齐的文本表格，便于终端查看
+func (t *DatacubeTable) WriteText(w io.Writer) error {
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(writer, strings.Join(t.Header, "\t")); err != nil {
+		return err
+	}
+
+	record := make([]string, len(t.Header))
+	for _, row := range t.Rows {
+		for i, value := range row {
+			record[i] = formatCell(value)
+		}
+		if _, err := fmt.Fprintln(writer, strings.Join(record, "\t")); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+// formatCell CSV 单元格
+func formatCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// flattenAll 扁平化多个同类型响应，如回填得到的各窗口结果
+func flattenAll(responses []interface{}) (*DatacubeTable, error) {
+
+	var table *DatacubeTable
+	for _, response := range responses {
+		t, err := FlattenDatacube(response)
+		if err != nil {
+			return nil, err
+		}
+		if table == nil {
+			table = t
+			continue
+		}
+		if err = table.Append(t); err != nil {
+			return nil, err
+		}
+	}
+	if table == nil {
+		return nil, errors.New("no datacube response to export")
+	}
+	return table, nil
+}
+
+// ExportDatacubeCSV 将同类型的数据分析响应导出为 CSV
+func ExportDatacubeCSV(w io.Writer, responses ...interface{}) error {
+	table, err := flattenAll(responses)
+	if err != nil {
+		return err
+	}
+	return table.WriteCSV(w)
+}
+
+// ExportDatacubeJSONLines 将同类型的数据分析响应导出为 JSON Lines
+func ExportDatacubeJSONLines(w io.Writer, responses ...interface{}) error {
+	table, err := flattenAll(responses)
+	if err != nil {
+		return err
+	}
+	return table.WriteJSONLines(w)
+}
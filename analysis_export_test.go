@@ -0,0 +1,86 @@
+package wechat
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExportDatacube(t *testing.T) {
+
+	retain := []interface{}{
+		&GetMonthlyRetainResponse{
+			RefDate:    "201702",
+			VisitUvNew: []MonthlyRetainVisitUvNew{{Key: 0, Value: 346249}},
+			VisitUv:    []MonthlyRetainVisitUv{{Key: 0, Value: 346249}, {Key: 1, Value: 12}},
+		},
+		GetMonthlyRetainResponse{RefDate: "201703"},
+	}
+
+	var buf bytes.Buffer
+	if err := ExportDatacubeCSV(&buf, retain...); err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := "ref_date,visit_uv_new_month_0,visit_uv_new_month_1,visit_uv_month_0,visit_uv_month_1\n" +
+		"201702,346249,,346249,12\n" +
+		"201703,,,,\n"
+	if buf.String() != want {
+		t.Fatalf("csv:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := ExportDatacubeJSONLines(&buf, retain[0]); err != nil {
+		t.Fatalf("%v", err)
+	}
+	want = `{"ref_date":"201702","visit_uv_new_month_0":346249,"visit_uv_new_month_1":null,"visit_uv_month_0":346249,"visit_uv_month_1":12}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("jsonl:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	trend := &GetDailyVisitTrendResponse{List: []DailyVisitTrend{
+		{RefDate: "20170313", SessionCnt: 142549, VisitPV: 472351, VisitUV: 55500, VisitUvNew: 5464, StayTimeSession: 0, VisitDepth: 1.9838},
+	}}
+	buf.Reset()
+	if err := ExportDatacubeCSV(&buf, trend); err != nil {
+		t.Fatalf("%v", err)
+	}
+	want = "ref_date,session_cnt,visit_pv,visit_uv,visit_uv_new,stay_time_uv,stay_time_session,visit_depth\n" +
+		"20170313,142549,472351,55500,5464,0,0,1.9838\n"
+	if buf.String() != want {
+		t.Fatalf("csv:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	page := &GetVisitPageResponse{RefDate: "20170313", List: []VisitPage{{PagePath: "pages/main/main", PageVisitPV: 10}}}
+	table, err := FlattenDatacube(page)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if table.Header[0] != "ref_date" || table.Header[1] != "page_path" || table.Rows[0][0] != "20170313" {
+		t.Fatalf("page table: %+v", table)
+	}
+
+	distribution := &GetVisitDistributionResponse{RefDate: "20170313", List: []VisitDistribution{
+		{Index: DistributionDepth, ItemList: []VisitDistributionItem{{Key: 2, Value: 732}}},
+	}}
+	buf.Reset()
+	if err = ExportDatacubeJSONLines(&buf, distribution); err != nil {
+		t.Fatalf("%v", err)
+	}
+	want = `{"ref_date":"20170313","index":"access_depth_info","key":2,"key_name":"2页","value":732}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("jsonl:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	if err = ExportDatacubeCSV(&buf, trend, retain[0]); err == nil {
+		t.Fatalf("want header mismatch error")
+	}
+	if _, err = FlattenDatacube(&Code2SessionResponse{}); err == nil {
+		t.Fatalf("want unsupported type error")
+	}
+	if _, err = FlattenDatacube((*GetDailyRetainResponse)(nil)); err == nil {
+		t.Fatalf("want nil response error")
+	}
+	unexpected := &GetDailyRetainResponse{RefDate: "20170313", VisitUv: []DailyRetainVisitUv{{Key: 15, Value: 1}}}
+	if _, err = FlattenDatacube(unexpected); err == nil {
+		t.Fatalf("want unexpected retain key error")
+	}
+}
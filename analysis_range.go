@@ -0,0 +1,122 @@
+package wechat
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DatacubeDateLayout 数据分析接口日期格式 yyyymmdd
+const DatacubeDateLayout = "20060102"
+
+// period 数据分析接口要求的查询范围
+type period int
+
+const (
+	periodDaily   period = iota // 单日，开始日期与结束日期相同
+	periodWeekly                // 自然周，周一至周日
+	periodMonthly               // 自然月，1 日至月末
+	periodRecent                // 最近 1、7 或 30 天
+)
+
+var datacubePeriod = map[aims]period{
+	GetDailyRetain:       periodDaily,
+	GetMonthlyRetain:     periodMonthly,
+	GetWeeklyRetain:      periodWeekly,
+	GetDailySummary:      periodDaily,
+	GetDailyVisitTrend:   periodDaily,
+	GetMonthlyVisitTrend: periodMonthly,
+	GetWeeklyVisitTrend:  periodWeekly,
+	GetUserPortrait:      periodRecent,
+	GetVisitDistribution: periodDaily,
+	GetVisitPage:         periodDaily,
+}
+
+// recentDays 用户画像支持的查询天数
+var recentDays = map[int]bool{1: true, 7: true, 30: true}
+
+// date 取 t 所在时区的日期，统一为 UTC 零点便于按天计算
+func date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// newDatacubeRequest 按日期创建请求
+func newDatacubeRequest(begin, end time.Time) *GetDatacubeRequest {
+	return &GetDatacubeRequest{
+		BeginDate: begin.Format(DatacubeDateLayout),
+		EndDate:   end.Format(DatacubeDateLayout),
+	}
+}
+
+// NewDailyRange 查询 day 当天，用于日留存、概况、日趋势、访问分布、访问页面
+func NewDailyRange(day time.Time) *GetDatacubeRequest {
+	d := date(day)
+	return newDatacubeRequest(d, d)
+}
+
+// NewWeeklyRange 查询 day 所在自然周（周一至周日），用于周留存、周趋势
+func NewWeeklyRange(day time.Time) *GetDatacubeRequest {
+	d := date(day)
+	monday := d.AddDate(0, 0, -(int(d.Weekday())+6)%7)
+	return newDatacubeRequest(monday, monday.AddDate(0, 0, 6))
+}
+
+// NewMonthlyRange 查询 day 所在自然月，用于月留存、月趋势
+func NewMonthlyRange(day time.Time) *GetDatacubeRequest {
+	d := date(day)
+	first := time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return newDatacubeRequest(first, first.AddDate(0, 1, -1))
+}
+
+// NewRecentRange 查询截至 end 的最近 days 天，用于用户画像，days 只支持 1、7、30，其余返回错误
+func NewRecentRange(end time.Time, days int) (*GetDatacubeRequest, error) {
+	if !recentDays[days] {
+		return nil, errors.Errorf("recent range days must be 1, 7 or 30, got %d", days)
+	}
+	d := date(end)
+	return newDatacubeRequest(d.AddDate(0, 0, 1-days), d), nil
+}
+
+// Dates 解析开始与结束日期
+func (r *GetDatacubeRequest) Dates() (begin, end time.Time, err error) {
+	if begin, err = time.Parse(DatacubeDateLayout, r.BeginDate); err != nil {
+		return begin, end, errors.Wrap(err, "begin_date format error")
+	}
+	if end, err = time.Parse(DatacubeDateLayout, r.EndDate); err != nil {
+		return begin, end, errors.Wrap(err, "end_date format error")
+	}
+	return begin, end, nil
+}
+
+// validateDatacubeRange 按接口要求校验查询范围，避免请求后才收到 61500/61501
+func validateDatacubeRange(aims aims, r *GetDatacubeRequest) error {
+
+	begin, end, err := r.Dates()
+	if err != nil {
+		return err
+	}
+	if end.Before(begin) {
+		return errors.New("end_date must be no earlier than begin_date")
+	}
+
+	switch datacubePeriod[aims] {
+	case periodDaily:
+		if !end.Equal(begin) {
+			return errors.New("daily range must begin and end on the same day")
+		}
+	case periodWeekly:
+		if begin.Weekday() != time.Monday || !end.Equal(begin.AddDate(0, 0, 6)) {
+			return errors.New("weekly range must begin on Monday and end on the following Sunday")
+		}
+	case periodMonthly:
+		if begin.Day() != 1 || !end.Equal(begin.AddDate(0, 1, -1)) {
+			return errors.New("monthly range must cover a whole natural month")
+		}
+	case periodRecent:
+		if days := int(end.Sub(begin).Hours()/24) + 1; !recentDays[days] {
+			return errors.New("range must cover the last 1, 7 or 30 days")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package wechat
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDatacubeRanges(t *testing.T) {
+
+	// 2017-03-15 为周三
+	day := time.Date(2017, 3, 15, 23, 30, 0, 0, time.FixedZone("CST", 8*3600))
+
+	ranges := []struct {
+		req        *GetDatacubeRequest
+		begin, end string
+	}{
+		{NewDailyRange(day), "20170315", "20170315"},
+		{NewWeeklyRange(day), "20170313", "20170319"},
+		{NewWeeklyRange(time.Date(2017, 3, 19, 0, 0, 0, 0, time.UTC)), "20170313", "20170319"},
+		{NewMonthlyRange(day), "20170301", "20170331"},
+		{NewMonthlyRange(time.Date(2016, 2, 10, 0, 0, 0, 0, time.UTC)), "20160201", "20160229"},
+	}
+	for _, days := range []int{1, 7, 30} {
+		req, err := NewRecentRange(day, days)
+		if err != nil {
+			t.Fatalf("recent %d: %v", days, err)
+		}
+		if err = validateDatacubeRange(GetUserPortrait, req); err != nil {
+			t.Errorf("recent %d: %v", days, err)
+		}
+		if days == 7 && (req.BeginDate != "20170309" || req.EndDate != "20170315") {
+			t.Errorf("recent 7: %s-%s", req.BeginDate, req.EndDate)
+		}
+	}
+	for _, days := range []int{0, 3, 31, -7} {
+		if _, err := NewRecentRange(day, days); err == nil {
+			t.Errorf("recent %d: want error", days)
+		}
+	}
+	for _, tt := range ranges {
+		if tt.req.BeginDate != tt.begin || tt.req.EndDate != tt.end {
+			t.Errorf("range %s-%s, want %s-%s", tt.req.BeginDate, tt.req.EndDate, tt.begin, tt.end)
+		}
+	}
+
+	tests := []struct {
+		aims       aims
+		begin, end string
+		valid      bool
+	}{
+		{GetDailyVisitTrend, "20170315", "20170315", true},
+		{GetDailyVisitTrend, "20170315", "20170316", false},
+		{GetDailyRetain, "20170316", "20170315", false},
+		{GetWeeklyRetain, "20170313", "20170319", true},
+		{GetWeeklyRetain, "20170314", "20170320", false},
+		{GetWeeklyVisitTrend, "20170313", "20170320", false},
+		{GetMonthlyRetain, "20170201", "20170228", true},
+		{GetMonthlyVisitTrend, "20170201", "20170227", false},
+		{GetMonthlyVisitTrend, "20170202", "20170301", false},
+		{GetUserPortrait, "20170315", "20170315", true},
+		{GetUserPortrait, "20170214", "20170315", true},
+		{GetUserPortrait, "20170310", "20170315", false},
+		{GetVisitPage, "2017-03-15", "2017-03-15", false},
+	}
+	for _, tt := range tests {
+		err := validateDatacubeRange(tt.aims, &GetDatacubeRequest{BeginDate: tt.begin, EndDate: tt.end})
+		if (err == nil) != tt.valid {
+			t.Errorf("%s %s-%s: err %v, want valid %v", datacubePath[tt.aims], tt.begin, tt.end, err, tt.valid)
+		}
+	}
+}
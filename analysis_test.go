@@ -0,0 +1,226 @@
+package wechat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTypedDatacube(t *testing.T) {
+
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		fmt.Fprint(w, `{"ref_date":"20170313","list":[{"ref_date":"20170313","page_path":"pages/main/main"}]}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL))
+	day := time.Date(2017, 3, 13, 0, 0, 0, 0, time.UTC)
+	req := NewDailyRange(day)
+	weekly := NewWeeklyRange(day)
+	monthly := NewMonthlyRange(day)
+	recent, err := NewRecentRange(day, 7)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	tests := []struct {
+		aims aims
+		call func() (string, error)
+	}{
+		{GetDailyRetain, func() (string, error) {
+			resp, err := client.GetDailyRetain("token", req)
+			if err != nil {
+				return "", err
+			}
+			return resp.RefDate, nil
+		}},
+		{GetMonthlyRetain, func() (string, error) {
+			resp, err := client.GetMonthlyRetain("token", monthly)
+			if err != nil {
+				return "", err
+			}
+			return resp.RefDate, nil
+		}},
+		{GetWeeklyRetain, func() (string, error) {
+			resp, err := client.GetWeeklyRetain("token", weekly)
+			if err != nil {
+				return "", err
+			}
+			return resp.RefDate, nil
+		}},
+		{GetDailySummary, func() (string, error) {
+			resp, err := client.GetDailySummary("token", req)
+			if err != nil {
+				return "", err
+			}
+			return resp.List[0].RefDate, nil
+		}},
+		{GetDailyVisitTrend, func() (string, error) {
+			resp, err := client.GetDailyVisitTrend("token", req)
+			if err != nil {
+				return "", err
+			}
+			return resp.List[0].RefDate, nil
+		}},
+		{GetMonthlyVisitTrend, func() (string, error) {
+			resp, err := client.GetMonthlyVisitTrend("token", monthly)
+			if err != nil {
+				return "", err
+			}
+			return resp.List[0].RefDate, nil
+		}},
+		{GetWeeklyVisitTrend, func() (string, error) {
+			resp, err := client.GetWeeklyVisitTrend("token", weekly)
+			if err != nil {
+				return "", err
+			}
+			return resp.List[0].RefDate, nil
+		}},
+		{GetUserPortrait, func() (string, error) {
+			resp, err := client.GetUserPortrait("token", recent)
+			if err != nil {
+				return "", err
+			}
+			return resp.RefDate, nil
+		}},
+		{GetVisitDistribution, func() (string, error) {
+			resp, err := client.GetVisitDistribution("token", req)
+			if err != nil {
+				return "", err
+			}
+			return resp.RefDate, nil
+		}},
+		{GetVisitPage, func() (string, error) {
+			resp, err := client.GetVisitPage("token", req)
+			if err != nil {
+				return "", err
+			}
+			return resp.RefDate, nil
+		}},
+	}
+
+	for _, tt := range tests {
+		refDate, err := tt.call()
+		if err != nil {
+			t.Fatalf("%s: %v", datacubePath[tt.aims], err)
+		}
+		if path != datacubePath[tt.aims] {
+			t.Errorf("path: %s, want %s", path, datacubePath[tt.aims])
+		}
+		if refDate != "20170313" {
+			t.Errorf("%s: ref_date %q", path, refDate)
+		}
+	}
+
+	path = ""
+	if err := client.GetDatacube(aims(999), "token", req, new(GetDailyRetainResponse)); err == nil {
+		t.Fatal("unknown datacube api should fail")
+	}
+	if path != "" {
+		t.Fatalf("unknown datacube api requested %s", path)
+	}
+}
+
+func TestDatacubeModels(t *testing.T) {
+
+	portrait := new(GetUserPortraitResponse)
+	if err := json.Unmarshal([]byte(`{
+		"ref_date": "20170611",
+		"visit_uv_new": {
+			"province": [{"id": 31, "name": "广东省", "value": 215}],
+			"city": [{"id": 3102, "name": "广州", "value": 78}],
+			"genders": [{"id": 1, "name": "男", "value": 2146}],
+			"platforms": [{"id": 1, "name": "iPhone", "value": 27642}],
+			"devices": [{"name": "OPPO R9", "value": 61}],
+			"ages": [{"id": 1, "name": "17岁以下", "value": 151}]
+		},
+		"visit_uv": {"genders": [{"id": 2, "name": "女", "value": 100}]}
+	}`), portrait); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if n := portrait.VisitUvNew; n.Province[0].Value != 215 || n.Devices[0].Name != "OPPO R9" ||
+		n.Platforms[0].ID != PlatformIPhone || n.Ages[0].ID != AgeUnder17 || portrait.VisitUv.Genders[0].ID != GenderFemale {
+		t.Fatalf("portrait: %+v", portrait)
+	}
+
+	distribution := new(GetVisitDistributionResponse)
+	if err := json.Unmarshal([]byte(`{
+		"ref_date": "20170313",
+		"list": [
+			{"index": "access_source_session_cnt", "item_list": [{"key": 10, "value": 5}, {"key": 8, "value": 687}]},
+			{"index": "access_staytime_info", "item_list": [{"key": 1, "value": 3274}]},
+			{"index": "access_depth_info", "item_list": [{"key": 2, "value": 732}]}
+		]
+	}`), distribution); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if items := distribution.Distribution(DistributionAccessSource); len(items) != 2 || items[1].Value != 687 {
+		t.Fatalf("access source: %+v", items)
+	}
+	if distribution.Distribution("unknown") != nil {
+		t.Fatalf("unknown index should be nil")
+	}
+
+	names := []struct {
+		index string
+		key   int
+		want  string
+	}{
+		{DistributionAccessSource, 8, "小程序主页"},
+		{DistributionStayTime, 1, "0-2s"},
+		{DistributionDepth, 7, ">10页"},
+		{DistributionDepth, 99, ""},
+		{"unknown", 1, ""},
+	}
+	for _, tt := range names {
+		if got := DistributionKeyName(tt.index, tt.key); got != tt.want {
+			t.Errorf("DistributionKeyName(%s, %d) = %q, want %q", tt.index, tt.key, got, tt.want)
+		}
+	}
+
+	page := new(GetVisitPageResponse)
+	if err := json.Unmarshal([]byte(`{
+		"ref_date": "20170313",
+		"list": [{"page_path": "pages/main/main.html", "page_visit_pv": 213429, "page_visit_uv": 55423,
+			"page_staytime_pv": 8.139198, "entrypage_pv": 117922, "exitpage_pv": 61304, "page_share_pv": 180, "page_share_uv": 166}]
+	}`), page); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if p := page.List[0]; p.PageVisitPV != 213429 || p.EntrypagePV != 117922 || p.ExitpagePV != 61304 || p.PageSharePV != 180 {
+		t.Fatalf("page: %+v", p)
+	}
+
+	weekly := new(GetWeeklyVisitTrendResponse)
+	if err := json.Unmarshal([]byte(`{"list": [{"ref_date": "20170306-20170312", "session_cnt": 986780,
+		"visit_pv": 3251840, "visit_uv": 189405, "visit_uv_new": 45592, "stay_time_session": 54.5346, "visit_depth": 1.9735}]}`), weekly); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if w := weekly.List[0]; w.RefDate != "20170306-20170312" || w.VisitUvNew != 45592 || w.VisitDepth != 1.9735 {
+		t.Fatalf("weekly: %+v", w)
+	}
+}
+
+func TestParseDatacube(t *testing.T) {
+
+	for name, want := range map[string]aims{
+		"getDailyRetain": GetDailyRetain,
+		"dailyretain":    GetDailyRetain,
+		"GETVISITPAGE":   GetVisitPage,
+		"userPortrait":   GetUserPortrait,
+	} {
+		if a, err := ParseDatacube(name); err != nil || a != want {
+			t.Fatalf("%s: %v %v", name, a, err)
+		}
+	}
+	if _, err := ParseDatacube("retain"); err == nil {
+		t.Fatal("unknown name should fail")
+	}
+	if names := DatacubeNames(); len(names) != 10 || names[0] != GetDailyRetain.String() {
+		t.Fatalf("names: %v", names)
+	}
+}
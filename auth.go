@@ -1,6 +1,11 @@
 package wechat
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/pkg/errors"
 )
@@ -25,7 +30,7 @@ type Code2SessionRequest struct {
 
 // Code2SessionResponse 登录凭证校验-响应
 type Code2SessionResponse struct {
-	OpenID     string `json:"open_id"`     //用户唯一标识
+	OpenID     string `json:"openid"`      //用户唯一标识
 	SessionKey string `json:"session_key"` //会话密钥
 	UnionID    string `json:"unionid"`     //用户在开放平台的唯一标识符，在满足 UnionID 下发条件的情况下会返回，详见 UnionID 机制说明。
 }
@@ -33,7 +38,23 @@ type Code2SessionResponse struct {
 // Code2Session 登录凭证校验。通过 wx.login 接口获得临时登录凭证 code 后传到开发者服务器调用此接口完成登录流程。更多使用方法详见 小程序登录。
 // https://developers.weixin.qq.com/miniprogram/dev/api-backend/open-api/login/auth.code2Session.html
 func Code2Session(req *Code2SessionRequest, resp *Code2SessionResponse) error {
+	return DefaultClient.Code2Session(req, resp)
+}
+
+// Code2SessionContext 同 Code2Session，ctx 用于取消请求或设置超时
+func Code2SessionContext(ctx context.Context, req *Code2SessionRequest, resp *Code2SessionResponse) error {
+	return DefaultClient.Code2SessionContext(ctx, req, resp)
+}
 
+// Code2Session 登录凭证校验，AppID、Secret 为空时使用客户端凭证
+func (c *Client) Code2Session(req *Code2SessionRequest, resp *Code2SessionResponse) error {
+	return c.Code2SessionContext(context.Background(), req, resp)
+}
+
+// Code2SessionContext 同 Code2Session，ctx 用于取消请求或设置超时
+func (c *Client) Code2SessionContext(ctx context.Context, req *Code2SessionRequest, resp *Code2SessionResponse) error {
+
+	c.fillCredential(&req.AppID, &req.Secret)
 	req.GrantType = GrantTypeAuthorizationCode
 
 	if err := validation.ValidateStruct(req,
@@ -44,9 +65,9 @@ func Code2Session(req *Code2SessionRequest, resp *Code2SessionResponse) error {
 		return errors.Wrap(err, "request param error")
 	}
 
-	URL := "https://api.weixin.qq.com/sns/jscode2session"
+	URL := c.apiURL("/sns/jscode2session")
 
-	return httpGetJSON(DefaultHTTPClient, URL, req, resp)
+	return c.httpGetJSON(ctx, URL, req, resp)
 }
 
 // GetPaidUnionIDRequest 获取UnionID-请求
@@ -66,17 +87,31 @@ type GetPaidUnionIDResponse struct {
 // GetPaidUnionID 用户支付完成后，获取该用户的 UnionId，无需用户授权
 // https://developers.weixin.qq.com/miniprogram/dev/api-backend/open-api/user-info/auth.getPaidUnionId.html
 func GetPaidUnionID(req *GetPaidUnionIDRequest, resp *GetPaidUnionIDResponse) error {
+	return DefaultClient.GetPaidUnionID(req, resp)
+}
+
+// GetPaidUnionIDContext 同 GetPaidUnionID，ctx 用于取消请求或设置超时
+func GetPaidUnionIDContext(ctx context.Context, req *GetPaidUnionIDRequest, resp *GetPaidUnionIDResponse) error {
+	return DefaultClient.GetPaidUnionIDContext(ctx, req, resp)
+}
+
+// GetPaidUnionID 用户支付完成后，获取该用户的 UnionId，AccessToken 为空时由凭证管理器提供
+func (c *Client) GetPaidUnionID(req *GetPaidUnionIDRequest, resp *GetPaidUnionIDResponse) error {
+	return c.GetPaidUnionIDContext(context.Background(), req, resp)
+}
+
+// GetPaidUnionIDContext 同 GetPaidUnionID，ctx 用于取消请求或设置超时
+func (c *Client) GetPaidUnionIDContext(ctx context.Context, req *GetPaidUnionIDRequest, resp *GetPaidUnionIDResponse) error {
 
 	if err := validation.ValidateStruct(req,
-		validation.Field(&req.AccessToken, validation.Required),
 		validation.Field(&req.Openid, validation.Required),
 	); err != nil {
 		return errors.Wrap(err, "request param error")
 	}
 
-	URL := "https://api.weixin.qq.com/wxa/getpaidunionid"
+	URL := c.apiURL("/wxa/getpaidunionid")
 
-	return httpGetJSON(DefaultHTTPClient, URL, req, resp)
+	return c.httpGetJSON(ctx, URL, req, resp)
 }
 
 // GetAccessTokenRequest 获取凭证-请求
@@ -95,7 +130,23 @@ type GetAccessTokenResponse struct {
 // GetAccessToken 获取小程序全局唯一后台接口调用凭据（access_token）。调用绝大多数后台接口时都需使用 access_token，开发者需要进行妥善保存。
 // https://developers.weixin.qq.com/miniprogram/dev/api-backend/open-api/access-token/auth.getAccessToken.html
 func GetAccessToken(req *GetAccessTokenRequest, resp *GetAccessTokenResponse) error {
+	return DefaultClient.GetAccessToken(req, resp)
+}
+
+// GetAccessTokenContext 同 GetAccessToken，ctx 用于取消请求或设置超时
+func GetAccessTokenContext(ctx context.Context, req *GetAccessTokenRequest, resp *GetAccessTokenResponse) error {
+	return DefaultClient.GetAccessTokenContext(ctx, req, resp)
+}
+
+// GetAccessToken 获取接口调用凭据，AppID、Secret 为空时使用客户端凭证
+func (c *Client) GetAccessToken(req *GetAccessTokenRequest, resp *GetAccessTokenResponse) error {
+	return c.GetAccessTokenContext(context.Background(), req, resp)
+}
+
+// GetAccessTokenContext 同 GetAccessToken，ctx 用于取消请求或设置超时
+func (c *Client) GetAccessTokenContext(ctx context.Context, req *GetAccessTokenRequest, resp *GetAccessTokenResponse) error {
 
+	c.fillCredential(&req.AppID, &req.Secret)
 	req.GrantType = GrantTypeClientCredential
 
 	if err := validation.ValidateStruct(req,
@@ -105,7 +156,120 @@ func GetAccessToken(req *GetAccessTokenRequest, resp *GetAccessTokenResponse) er
 		return errors.Wrap(err, "request param error")
 	}
 
-	URL := "https://api.weixin.qq.com/cgi-bin/token"
+	URL := c.apiURL("/cgi-bin/token")
+
+	return c.httpGetJSON(ctx, URL, req, resp)
+}
+
+// SigMethodHMACSHA256 用户登录态签名方法
+const SigMethodHMACSHA256 = "hmac_sha256"
+
+// SessionKeySignature 用户登录态签名，以 session_key 为密钥对空字符串做 HMAC-SHA256，十六进制编码
+func SessionKeySignature(sessionKey string) string {
+	mac := hmac.New(sha256.New, []byte(sessionKey))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sessionKeyRequest 用户登录态校验-请求
+type sessionKeyRequest struct {
+	AccessToken string `json:"access_token"` //接口调用凭证
+	OpenID      string `json:"openid"`       //用户唯一标识符
+	Signature   string `json:"signature"`    //用户登录态签名
+	SigMethod   string `json:"sig_method"`   //用户登录态签名的哈希方法，目前只支持 hmac_sha256
+}
+
+// newSessionKeyRequest 校验参数并计算签名
+func newSessionKeyRequest(accessToken, openID, sessionKey string) (*sessionKeyRequest, error) {
+
+	if err := (validation.Errors{
+		"openid":      validation.Validate(openID, validation.Required),
+		"session_key": validation.Validate(sessionKey, validation.Required),
+	}).Filter(); err != nil {
+		return nil, errors.Wrap(err, "request param error")
+	}
+
+	return &sessionKeyRequest{
+		AccessToken: accessToken,
+		OpenID:      openID,
+		Signature:   SessionKeySignature(sessionKey),
+		SigMethod:   SigMethodHMACSHA256,
+	}, nil
+}
+
+// ResetUserSessionKeyResponse 重置用户的 session_key-响应
+type ResetUserSessionKeyResponse struct {
+	OpenID     string `json:"openid"`      //用户唯一标识
+	SessionKey string `json:"session_key"` //重置后的会话密钥
+}
+
+// CheckSessionKey 校验服务器所保存的登录态 session_key 是否合法，session_key 失效时返回 ErrInvalidSignature
+// https://developers.weixin.qq.com/miniprogram/dev/OpenApiDoc/user-login/checkSessionKey.html
+func CheckSessionKey(accessToken, openID, sessionKey string) error {
+	return DefaultClient.CheckSessionKey(accessToken, openID, sessionKey)
+}
+
+// CheckSessionKeyContext 同 CheckSessionKey，ctx 用于取消请求或设置超时
+func CheckSessionKeyContext(ctx context.Context, accessToken, openID, sessionKey string) error {
+	return DefaultClient.CheckSessionKeyContext(ctx, accessToken, openID, sessionKey)
+}
+
+// CheckSessionKey 校验登录态 session_key 是否合法，accessToken 为空时由凭证管理器提供
+func (c *Client) CheckSessionKey(accessToken, openID, sessionKey string) error {
+	return c.CheckSessionKeyContext(context.Background(), accessToken, openID, sessionKey)
+}
+
+// CheckSessionKeyContext 同 CheckSessionKey，ctx 用于取消请求或设置超时
+func (c *Client) CheckSessionKeyContext(ctx context.Context, accessToken, openID, sessionKey string) error {
+
+	req, err := newSessionKeyRequest(accessToken, openID, sessionKey)
+	if err != nil {
+		return err
+	}
+
+	URL := c.apiURL("/wxa/checksession")
+
+	return c.httpGetJSON(ctx, URL, req, nil)
+}
 
-	return httpGetJSON(DefaultHTTPClient, URL, req, resp)
+// ResetUserSessionKey 重置指定的用户登录态 session_key，sessionKey 为当前的会话密钥
+// https://developers.weixin.qq.com/miniprogram/dev/OpenApiDoc/user-login/ResetUserSessionKey.html
+func ResetUserSessionKey(accessToken, openID, sessionKey string) (*ResetUserSessionKeyResponse, error) {
+	return DefaultClient.ResetUserSessionKey(accessToken, openID, sessionKey)
+}
+
+// ResetUserSessionKeyContext 同 ResetUserSessionKey，ctx 用于取消请求或设置超时
+func ResetUserSessionKeyContext(ctx context.Context, accessToken, openID, sessionKey string) (*ResetUserSessionKeyResponse, error) {
+	return DefaultClient.ResetUserSessionKeyContext(ctx, accessToken, openID, sessionKey)
+}
+
+// ResetUserSessionKey 重置用户登录态 session_key，accessToken 为空时由凭证管理器提供
+func (c *Client) ResetUserSessionKey(accessToken, openID, sessionKey string) (*ResetUserSessionKeyResponse, error) {
+	return c.ResetUserSessionKeyContext(context.Background(), accessToken, openID, sessionKey)
+}
+
+// ResetUserSessionKeyContext 同 ResetUserSessionKey，ctx 用于取消请求或设置超时
+func (c *Client) ResetUserSessionKeyContext(ctx context.Context, accessToken, openID, sessionKey string) (*ResetUserSessionKeyResponse, error) {
+
+	req, err := newSessionKeyRequest(accessToken, openID, sessionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	URL := c.apiURL("/wxa/resetusersessionkey")
+
+	resp := new(ResetUserSessionKeyResponse)
+	if err = c.httpGetJSON(ctx, URL, req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// fillCredential 请求未携带凭证时使用客户端凭证
+func (c *Client) fillCredential(appID, secret *string) {
+	if *appID == "" {
+		*appID = c.appID
+	}
+	if *secret == "" {
+		*secret = c.secret
+	}
 }
@@ -1,61 +1,141 @@
 package wechat
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/jayecc/wechat/wechattest"
+	"github.com/pkg/errors"
+)
 
 func TestCode2Session(t *testing.T) {
 
-	req := new(Code2SessionRequest)
-	resp := new(Code2SessionResponse)
+	server := wechattest.NewServer()
+	defer server.Close()
+	server.AddCode("CODE", wechattest.Session{OpenID: "OPENID", SessionKey: "SESSIONKEY", UnionID: "UNIONID"})
 
-	req = &Code2SessionRequest{
-		AppID:  "111",
-		Secret: "q",
-		JsCode: "w",
-	}
+	client := NewClient(WithBaseURL(server.URL), WithCredential(server.AppID, server.Secret))
 
-	if err := Code2Session(req, resp); err != nil {
+	resp := new(Code2SessionResponse)
+	if err := client.Code2Session(&Code2SessionRequest{JsCode: "CODE"}, resp); err != nil {
 		t.Fatalf("%v", err)
 	}
+	if resp.OpenID != "OPENID" || resp.SessionKey != "SESSIONKEY" || resp.UnionID != "UNIONID" {
+		t.Fatalf("resp: %+v", resp)
+	}
+	server.AssertQuery(t, "/sns/jscode2session", "grant_type", string(GrantTypeAuthorizationCode))
 
-	t.Log(resp)
-	t.Log("ok")
+	if err := client.Code2Session(&Code2SessionRequest{JsCode: "CODE"}, resp); !errors.Is(err, ErrCodeBeenUsed) {
+		t.Fatalf("err: %v", err)
+	}
+	if err := client.Code2Session(&Code2SessionRequest{JsCode: "UNKNOWN"}, resp); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("err: %v", err)
+	}
+	if err := client.Code2Session(&Code2SessionRequest{Secret: "WRONG", JsCode: "CODE"}, resp); !errors.Is(err, ErrInvalidAppSecret) {
+		t.Fatalf("err: %v", err)
+	}
+	server.AssertCalled(t, "/sns/jscode2session", 4)
 }
 
 func TestGetPaidUnionId(t *testing.T) {
 
-	req := new(GetPaidUnionIDRequest)
+	server := wechattest.NewServer()
+	defer server.Close()
+	server.AddPaidUnionID("OPENID", "UNIONID")
+
+	client := NewClient(WithBaseURL(server.URL), WithCredential(server.AppID, server.Secret))
+
 	resp := new(GetPaidUnionIDResponse)
+	if err := client.GetPaidUnionID(&GetPaidUnionIDRequest{Openid: "OPENID", TransactionID: "TRANSACTIONID"}, resp); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if resp.UnionID != "UNIONID" {
+		t.Fatalf("resp: %+v", resp)
+	}
+	server.AssertQuery(t, "/wxa/getpaidunionid", "transaction_id", "TRANSACTIONID")
 
-	req = &GetPaidUnionIDRequest{
-		AccessToken:   "xxxx",
-		Openid:        "22",
-		TransactionID: "",
-		MchID:         "",
-		OutTradeNO:    "",
+	// 显式传入的 access_token 失效时不重放
+	err := client.GetPaidUnionID(&GetPaidUnionIDRequest{AccessToken: "xxxx", Openid: "OPENID"}, resp)
+	if !errors.Is(err, ErrInvalidCredential) {
+		t.Fatalf("err: %v", err)
 	}
 
-	if err := GetPaidUnionID(req, resp); err != nil {
+	// 托管的 access_token 过期后刷新并重放
+	server.ExpireTokens()
+	if err = client.GetPaidUnionID(&GetPaidUnionIDRequest{Openid: "OPENID"}, resp); err != nil {
 		t.Fatalf("%v", err)
 	}
+	server.AssertCalled(t, "/cgi-bin/token", 2)
 
-	t.Log(resp)
-	t.Log("ok")
+	err = client.GetPaidUnionID(&GetPaidUnionIDRequest{Openid: "UNKNOWN"}, resp)
+	if !errors.Is(err, ErrInvalidOrder) {
+		t.Fatalf("err: %v", err)
+	}
 }
 
 func TestGetAccessToken(t *testing.T) {
 
-	req := new(GetAccessTokenRequest)
+	server := wechattest.NewServer()
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithRetryPolicy(RetryPolicy{}))
+
 	resp := new(GetAccessTokenResponse)
+	if err := client.GetAccessToken(&GetAccessTokenRequest{AppID: server.AppID, Secret: server.Secret}, resp); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if resp.AccessToken == "" || resp.ExpiresIn != wechattest.DefaultExpiresIn {
+		t.Fatalf("resp: %+v", resp)
+	}
+
+	err := client.GetAccessToken(&GetAccessTokenRequest{AppID: "xxxx", Secret: "xxxx"}, resp)
+	if !errors.Is(err, ErrInvalidAppID) {
+		t.Fatalf("err: %v", err)
+	}
 
-	req = &GetAccessTokenRequest{
-		AppID:  "xxxx",
-		Secret: "xxxx",
+	server.FailNext("/cgi-bin/token", ErrCodeSystemBusy, "system error")
+	err = client.GetAccessToken(&GetAccessTokenRequest{AppID: server.AppID, Secret: server.Secret}, resp)
+	if !IsRetryable(err) {
+		t.Fatalf("err: %v", err)
 	}
+	server.AssertCalled(t, "/cgi-bin/token", 3)
+}
+
+func TestSessionKey(t *testing.T) {
+
+	if sig := SessionKeySignature("o0q0otL8aEzpcZL/FT9WsQ=="); sig != "46e043c5525c2d817c44be603d30837a808a1d930d038f6fdc3e62a201fed128" {
+		t.Fatalf("signature: %s", sig)
+	}
+
+	server := wechattest.NewServer()
+	defer server.Close()
+	server.AddCode("CODE", wechattest.Session{OpenID: "OPENID", SessionKey: "o0q0otL8aEzpcZL/FT9WsQ=="})
+
+	client := NewClient(WithBaseURL(server.URL), WithCredential(server.AppID, server.Secret))
 
-	if err := GetAccessToken(req, resp); err != nil {
+	if err := client.CheckSessionKey("", "OPENID", "o0q0otL8aEzpcZL/FT9WsQ=="); err != nil {
 		t.Fatalf("%v", err)
 	}
+	server.AssertQuery(t, "/wxa/checksession", "sig_method", SigMethodHMACSHA256)
+	server.AssertQuery(t, "/wxa/checksession", "signature", SessionKeySignature("o0q0otL8aEzpcZL/FT9WsQ=="))
 
-	t.Log(resp)
-	t.Log("ok")
+	if err := client.CheckSessionKey("", "OPENID", "stale"); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("err: %v", err)
+	}
+	if err := client.CheckSessionKey("", "", "stale"); err == nil || errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("err: %v", err)
+	}
+
+	resp, err := client.ResetUserSessionKey("", "OPENID", "o0q0otL8aEzpcZL/FT9WsQ==")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if resp.OpenID != "OPENID" || resp.SessionKey == "" || resp.SessionKey != server.SessionKey("OPENID") {
+		t.Fatalf("resp: %+v", resp)
+	}
+	if err = client.CheckSessionKey("", "OPENID", "o0q0otL8aEzpcZL/FT9WsQ=="); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("old key should be invalid: %v", err)
+	}
+	if err = client.CheckSessionKey("", "OPENID", resp.SessionKey); err != nil {
+		t.Fatalf("%v", err)
+	}
 }
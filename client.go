@@ -0,0 +1,159 @@
+package wechat
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL 微信接口默认地址
+const DefaultBaseURL = "https://api.weixin.qq.com"
+
+// DefaultClient 默认客户端，包级函数均通过它发起请求
+var DefaultClient = NewClient()
+
+// Client 小程序客户端，持有凭证、http客户端与接口地址，可同时创建多个以服务不同的小程序
+type Client struct {
+	appID      string
+	secret     string
+	httpClient *http.Client
+	baseURL    string
+	userAgent  string
+	tokens     *TokenManager
+	tokenOpts  []TokenOption
+	retry      RetryPolicy
+	limiter    *RateLimiter
+
+	interceptors    []Interceptor
+	watermarkMaxAge time.Duration
+}
+
+// ClientOption 客户端配置项
+type ClientOption func(*Client)
+
+// WithCredential 设置小程序 appId 和 appSecret
+func WithCredential(appID, secret string) ClientOption {
+	return func(c *Client) {
+		c.appID = appID
+		c.secret = secret
+	}
+}
+
+// WithHTTPClient 设置http客户端，未设置时使用 DefaultHTTPClient
+func WithHTTPClient(clt *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = clt
+	}
+}
+
+// WithBaseURL 设置接口地址，如测试时指向本地 httptest 服务
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithUserAgent 设置请求头 User-Agent
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithTokenOptions 设置客户端内置凭证管理器的配置
+func WithTokenOptions(opts ...TokenOption) ClientOption {
+	return func(c *Client) {
+		c.tokenOpts = append(c.tokenOpts, opts...)
+	}
+}
+
+// WithRetryPolicy 设置重试策略，传入零值 RetryPolicy{} 关闭重试
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// WithRateLimiter 设置按接口限流的令牌桶，默认不限流
+func WithRateLimiter(limiter *RateLimiter) ClientOption {
+	return func(c *Client) {
+		c.limiter = limiter
+	}
+}
+
+// WithInterceptors 追加拦截器，先追加的位于外层
+func WithInterceptors(interceptors ...Interceptor) ClientOption {
+	return func(c *Client) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+// WithWatermarkMaxAge 设置解密开放数据时水印时间戳与当前时间的最大时差，默认 DefaultWatermarkMaxAge，小于等于 0 时不校验
+func WithWatermarkMaxAge(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.watermarkMaxAge = d
+	}
+}
+
+// NewClient 创建客户端
+func NewClient(opts ...ClientOption) *Client {
+	c := &Client{
+		baseURL:         DefaultBaseURL,
+		retry:           DefaultRetryPolicy,
+		watermarkMaxAge: DefaultWatermarkMaxAge,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.tokens = NewTokenManager(c, c.tokenOpts...)
+	return c
+}
+
+// AppID 小程序 appId
+func (c *Client) AppID() string {
+	return c.appID
+}
+
+// BaseURL 接口地址
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// HTTPClient 当前使用的http客户端
+func (c *Client) HTTPClient() *http.Client {
+	if c.httpClient != nil {
+		return c.httpClient
+	}
+	return DefaultHTTPClient
+}
+
+// Tokens 客户端内置的凭证管理器
+func (c *Client) Tokens() *TokenManager {
+	return c.tokens
+}
+
+// AccessToken 获取由客户端管理的 access_token
+func (c *Client) AccessToken() (string, error) {
+	return c.tokens.Token()
+}
+
+// AccessTokenContext 同 AccessToken，ctx 用于取消请求或设置超时
+func (c *Client) AccessTokenContext(ctx context.Context) (string, error) {
+	return c.tokens.TokenContext(ctx)
+}
+
+// apiPath 接口路径，去掉接口地址自身的路径前缀，用作限流等按接口区分的键
+func (c *Client) apiPath(u *url.URL) string {
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return u.Path
+	}
+	return strings.TrimPrefix(u.Path, strings.TrimRight(base.Path, "/"))
+}
+
+// apiURL 拼接接口地址
+func (c *Client) apiURL(path string) string {
+	return c.baseURL + path
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jayecc/wechat"
+	"github.com/pkg/errors"
+)
+
+// runToken 获取 access_token 并输出有效期
+func runToken(ctx context.Context, e *env, args []string) error {
+
+	flags := flag.NewFlagSet("token", flag.ContinueOnError)
+	format := flags.String("format", "text", "输出格式 text 或 json")
+	if err := parseFlags(flags, e, args); err != nil {
+		return err
+	}
+
+	resp := new(wechat.GetAccessTokenResponse)
+	if err := e.client.GetAccessTokenContext(ctx, &wechat.GetAccessTokenRequest{}, resp); err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second).Format(time.RFC3339)
+
+	switch *format {
+	case "json":
+		return writeJSON(e.stdout, struct {
+			*wechat.GetAccessTokenResponse
+			ExpiresAt string `json:"expires_at"`
+		}{resp, expiresAt})
+	case "text":
+		_, err := fmt.Fprintf(e.stdout, "access_token: %s\nexpires_in:   %ds\nexpires_at:   %s\n", resp.AccessToken, resp.ExpiresIn, expiresAt)
+		return err
+	}
+	return usageError(flags, e, "unknown format %q", *format)
+}
+
+// runCode2Session 登录凭证校验
+func runCode2Session(ctx context.Context, e *env, args []string) error {
+
+	flags := flag.NewFlagSet("code2session", flag.ContinueOnError)
+	code := flags.String("code", "", "wx.login 获取的 code")
+	if err := parseFlags(flags, e, args); err != nil {
+		return err
+	}
+
+	resp := new(wechat.Code2SessionResponse)
+	if err := e.client.Code2SessionContext(ctx, &wechat.Code2SessionRequest{JsCode: *code}, resp); err != nil {
+		return err
+	}
+	return writeJSON(e.stdout, resp)
+}
+
+// runPaidUnionID 用户支付完成后获取 UnionID
+func runPaidUnionID(ctx context.Context, e *env, args []string) error {
+
+	req := new(wechat.GetPaidUnionIDRequest)
+	flags := flag.NewFlagSet("paid-unionid", flag.ContinueOnError)
+	flags.StringVar(&req.AccessToken, "access-token", "", "接口调用凭证，默认使用 appid、secret 获取")
+	flags.StringVar(&req.Openid, "openid", "", "支付用户唯一标识")
+	flags.StringVar(&req.TransactionID, "transaction-id", "", "微信支付订单号")
+	flags.StringVar(&req.MchID, "mch-id", "", "微信支付商户号，和 -out-trade-no 配合使用")
+	flags.StringVar(&req.OutTradeNO, "out-trade-no", "", "微信支付商户订单号，和 -mch-id 配合使用")
+	if err := parseFlags(flags, e, args); err != nil {
+		return err
+	}
+
+	resp := new(wechat.GetPaidUnionIDResponse)
+	if err := e.client.GetPaidUnionIDContext(ctx, req, resp); err != nil {
+		return err
+	}
+	return writeJSON(e.stdout, resp)
+}
+
+// runDatacube 拉取 begin 至 end 的数据分析，按接口要求拆分查询窗口
+func runDatacube(ctx context.Context, e *env, args []string) error {
+
+	yesterday := time.Now().AddDate(0, 0, -1).Format(wechat.DatacubeDateLayout)
+
+	flags := flag.NewFlagSet("datacube", flag.ContinueOnError)
+	api := flags.String("api", "", "接口名称："+strings.Join(wechat.DatacubeNames(), "、"))
+	beginDate := flags.String("begin", yesterday, "开始日期 yyyymmdd")
+	endDate := flags.String("end", "", "结束日期 yyyymmdd，默认与开始日期相同")
+	format := flags.String("format", "table", "输出格式 table、json（JSON Lines）或 csv")
+	concurrency := flags.Int("concurrency", 4, "最大并发请求数")
+	if err := parseFlags(flags, e, args); err != nil {
+		return err
+	}
+
+	aims, err := wechat.ParseDatacube(*api)
+	if err != nil {
+		return usageError(flags, e, "%v", err)
+	}
+	if *endDate == "" {
+		*endDate = *beginDate
+	}
+	begin, end, err := (&wechat.GetDatacubeRequest{BeginDate: *beginDate, EndDate: *endDate}).Dates()
+	if err != nil {
+		return usageError(flags, e, "%v", err)
+	}
+	if end.Before(begin) {
+		return usageError(flags, e, "end date %s is before begin date %s", *endDate, *beginDate)
+	}
+
+	var write func(w io.Writer, responses ...interface{}) error
+	switch *format {
+	case "table":
+		write = writeTable
+	case "json":
+		write = wechat.ExportDatacubeJSONLines
+	case "csv":
+		write = wechat.ExportDatacubeCSV
+	default:
+		return usageError(flags, e, "unknown format %q", *format)
+	}
+
+	var (
+		responses []interface{}
+		failed    int
+	)
+	for result := range e.client.Backfill(ctx, aims, "", begin, end, wechat.WithBackfillConcurrency(*concurrency)) {
+		if result.Err != nil {
+			failed++
+			printError(e.stderr, errors.Wrapf(result.Err, "%s %s-%s", aims, result.Request.BeginDate, result.Request.EndDate))
+			continue
+		}
+		responses = append(responses, result.Response)
+	}
+
+	if len(responses) > 0 {
+		if err = write(e.stdout, responses...); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d windows failed", failed, failed+len(responses))
+	}
+	return nil
+}
+
+// writeTable 输出对齐的文本表格
+func writeTable(w io.Writer, responses ...interface{}) error {
+
+	var table *wechat.DatacubeTable
+	for _, response := range responses {
+		t, err := wechat.FlattenDatacube(response)
+		if err != nil {
+			return err
+		}
+		if table == nil {
+			table = t
+		} else if err = table.Append(t); err != nil {
+			return err
+		}
+	}
+	return table.WriteText(w)
+}
+
+// writeJSON 输出缩进的 JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// usageError 输出参数错误与用法
+func usageError(flags *flag.FlagSet, e *env, format string, args ...interface{}) error {
+	fmt.Fprintf(e.stderr, format+"\n", args...)
+	flags.Usage()
+	return errUsage
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// config 配置文件
+type config struct {
+	AppID   string `json:"appid"`    //小程序 appId
+	Secret  string `json:"secret"`   //小程序 appSecret
+	BaseURL string `json:"base_url"` //接口地址，可选
+}
+
+// loadConfig 读取配置文件，环境变量 WECHAT_APPID、WECHAT_SECRET、WECHAT_BASE_URL 覆盖文件中的值
+func loadConfig(file string) (*config, error) {
+
+	cfg := new(config)
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "read config error")
+		}
+		if err = json.Unmarshal(data, cfg); err != nil {
+			return nil, errors.Wrap(err, "parse config error")
+		}
+	}
+
+	for name, value := range map[string]*string{
+		"WECHAT_APPID":    &cfg.AppID,
+		"WECHAT_SECRET":   &cfg.Secret,
+		"WECHAT_BASE_URL": &cfg.BaseURL,
+	} {
+		if v := os.Getenv(name); v != "" {
+			*value = v
+		}
+	}
+
+	return cfg, nil
+}
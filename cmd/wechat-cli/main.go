@@ -0,0 +1,151 @@
+// wechat-cli 小程序服务端接口调试工具
+//
+// 用法：
+//
+//	wechat-cli [-config file] [-base-url url] [-token-dir dir] [-timeout 30s] [-v] <command> [flags]
+//
+// 凭证依次读取 -config 指定的 JSON 配置文件（或环境变量 WECHAT_CONFIG）与环境变量
+// WECHAT_APPID、WECHAT_SECRET，环境变量优先。command 为 token、code2session、
+// paid-unionid、datacube，执行 wechat-cli <command> -h 查看各命令参数
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jayecc/wechat"
+)
+
+// command 子命令
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, env *env, args []string) error
+}
+
+var commands = []command{
+	{"token", "获取 access_token 并输出有效期", runToken},
+	{"code2session", "登录凭证校验，-code 为 wx.login 获取的 code", runCode2Session},
+	{"paid-unionid", "用户支付完成后获取 UnionID", runPaidUnionID},
+	{"datacube", "拉取数据分析，输出为 table、json 或 csv", runDatacube},
+}
+
+// env 子命令的运行环境
+type env struct {
+	client *wechat.Client
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// errUsage 参数错误，已输出用法
+var errUsage = fmt.Errorf("usage error")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run 执行命令并返回退出码：0 成功，1 调用失败，2 参数错误
+func run(args []string, stdout, stderr io.Writer) int {
+
+	flags := flag.NewFlagSet("wechat-cli", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configFile := flags.String("config", os.Getenv("WECHAT_CONFIG"), "JSON 配置文件，包含 appid、secret、base_url")
+	baseURL := flags.String("base-url", "", "接口地址，默认 "+wechat.DefaultBaseURL)
+	tokenDir := flags.String("token-dir", "", "access_token 缓存目录，多次执行共用凭证，默认不缓存")
+	timeout := flags.Duration("timeout", 30*time.Second, "整个命令的超时时间")
+	verbose := flags.Bool("v", false, "输出每次请求的日志，凭证已脱敏")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: wechat-cli [flags] <command> [command flags]\n\nCommands:\n")
+		for _, cmd := range commands {
+			fmt.Fprintf(stderr, "  %-14s %s\n", cmd.name, cmd.usage)
+		}
+		fmt.Fprintf(stderr, "\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == flags.Arg(0) {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", flags.Arg(0))
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+	if *baseURL != "" {
+		cfg.BaseURL = *baseURL
+	}
+
+	opts := []wechat.ClientOption{wechat.WithCredential(cfg.AppID, cfg.Secret)}
+	if cfg.BaseURL != "" {
+		opts = append(opts, wechat.WithBaseURL(cfg.BaseURL))
+	}
+	if *tokenDir != "" {
+		store, err := wechat.NewFileTokenStore(*tokenDir)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 2
+		}
+		opts = append(opts, wechat.WithTokenOptions(wechat.WithTokenStore(store)))
+	}
+	if *verbose {
+		opts = append(opts, wechat.WithInterceptors(wechat.LoggingInterceptor(log.New(stderr, "", log.LstdFlags))))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	err = cmd.run(ctx, &env{client: wechat.NewClient(opts...), stdout: stdout, stderr: stderr}, flags.Args()[1:])
+	switch {
+	case err == nil:
+		return 0
+	case err == errUsage:
+		return 2
+	}
+	printError(stderr, err)
+	return 1
+}
+
+// printError 输出错误，微信返回的错误附带错误码说明
+func printError(w io.Writer, err error) {
+	fmt.Fprintf(w, "error: %v\n", err)
+	if e, ok := wechat.AsError(err); ok {
+		if text := wechat.ErrCodeText(e.ErrCode); text != "" {
+			fmt.Fprintf(w, "errcode %d: %s\n", e.ErrCode, text)
+		}
+	}
+}
+
+// parseFlags 解析子命令参数，-h 或参数错误时返回 errUsage
+func parseFlags(flags *flag.FlagSet, e *env, args []string) error {
+	flags.SetOutput(e.stderr)
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(e.stderr, "unexpected arguments %v\n", flags.Args())
+		flags.Usage()
+		return errUsage
+	}
+	return nil
+}
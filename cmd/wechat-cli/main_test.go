@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jayecc/wechat/wechattest"
+)
+
+func TestRun(t *testing.T) {
+
+	server := wechattest.NewServer()
+	defer server.Close()
+	server.AddCode("CODE", wechattest.Session{OpenID: "OPENID", SessionKey: "SESSIONKEY"})
+	server.ReplyJSON("/datacube/getweanalysisappiddailyvisittrend", json.RawMessage(
+		`{"list":[{"ref_date":"20170313","session_cnt":142549,"visit_pv":472351,"visit_uv":55500,"visit_uv_new":5464,"stay_time_session":0,"visit_depth":1.9838}]}`))
+
+	os.Setenv("WECHAT_APPID", server.AppID)
+	os.Setenv("WECHAT_SECRET", server.Secret)
+	defer os.Unsetenv("WECHAT_APPID")
+	defer os.Unsetenv("WECHAT_SECRET")
+
+	for _, tc := range []struct {
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{args: []string{"token", "-format", "json"}, stdout: `"access_token": "ACCESS_TOKEN_1"`},
+		{args: []string{"code2session", "-code", "CODE"}, stdout: `"session_key": "SESSIONKEY"`},
+		{args: []string{"code2session", "-code", "BAD"}, code: 1, stderr: "errcode 40029"},
+		{args: []string{"code2session"}, code: 1, stderr: "request param error"},
+		{args: []string{"datacube", "-api", "dailyVisitTrend", "-begin", "20170313", "-format", "csv"},
+			stdout: "ref_date,session_cnt,visit_pv,visit_uv,visit_uv_new,stay_time_uv,stay_time_session,visit_depth\n20170313,142549,"},
+		{args: []string{"datacube", "-api", "unknown"}, code: 2, stderr: "unknown datacube api"},
+		{args: []string{"datacube", "-api", "dailyVisitTrend", "-format", "xml"}, code: 2, stderr: "unknown format"},
+		{args: []string{"datacube", "-api", "dailyVisitTrend", "-begin", "20170313", "-end", "20170312"}, code: 2, stderr: "is before begin date"},
+		{args: []string{"nope"}, code: 2, stderr: "unknown command"},
+	} {
+		var stdout, stderr bytes.Buffer
+		code := run(append([]string{"-base-url", server.URL}, tc.args...), &stdout, &stderr)
+		if code != tc.code || !strings.Contains(stdout.String(), tc.stdout) || !strings.Contains(stderr.String(), tc.stderr) {
+			t.Fatalf("%v: code %d\nstdout: %s\nstderr: %s", tc.args, code, stdout.String(), stderr.String())
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package wechat
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+// MsgType 客服消息类型
+type MsgType string
+
+const (
+	// MsgTypeText 文本消息
+	MsgTypeText MsgType = "text"
+	// MsgTypeImage 图片消息
+	MsgTypeImage MsgType = "image"
+	// MsgTypeLink 图文链接
+	MsgTypeLink MsgType = "link"
+	// MsgTypeMiniProgramPage 小程序卡片
+	MsgTypeMiniProgramPage MsgType = "miniprogrampage"
+)
+
+// CustomerServiceMessage 客服消息，取值为 *TextMessage、*ImageMessage、*LinkMessage、*MiniProgramPageMessage 之一
+type CustomerServiceMessage interface {
+	validation.Validatable
+	// MsgType 消息类型，同时是请求中消息内容的字段名
+	MsgType() MsgType
+	customerServiceMessage()
+}
+
+// TextMessage 文本消息
+type TextMessage struct {
+	Content string `json:"content"` //文本消息内容
+}
+
+// ImageMessage 图片消息
+type ImageMessage struct {
+	MediaID string `json:"media_id"` //发送的图片的媒体ID，通过 UploadTempMedia 上传图片文件获得
+}
+
+// LinkMessage 图文链接
+type LinkMessage struct {
+	Title       string `json:"title"`       //消息标题
+	Description string `json:"description"` //图文链接消息
+	URL         string `json:"url"`         //图文链接消息被点击后跳转的链接
+	ThumbURL    string `json:"thumb_url"`   //图文链接消息的图片链接，支持 JPG、PNG 格式，较好的效果为大图 640 X 320，小图 80 X 80
+}
+
+// MiniProgramPageMessage 小程序卡片
+type MiniProgramPageMessage struct {
+	Title        string `json:"title"`          //消息标题
+	PagePath     string `json:"pagepath"`       //小程序的页面路径，跟 app.json 对齐，支持参数，比如 pages/index/index?foo=bar
+	ThumbMediaID string `json:"thumb_media_id"` //小程序消息卡片的封面，image 类型的 media_id，通过 UploadTempMedia 上传图片文件获得，建议大小为 520*416
+}
+
+// NewTextMessage 文本消息
+func NewTextMessage(content string) *TextMessage {
+	return &TextMessage{Content: content}
+}
+
+// NewImageMessage 图片消息
+func NewImageMessage(mediaID string) *ImageMessage {
+	return &ImageMessage{MediaID: mediaID}
+}
+
+// NewLinkMessage 图文链接
+func NewLinkMessage(title, description, URL, thumbURL string) *LinkMessage {
+	return &LinkMessage{Title: title, Description: description, URL: URL, ThumbURL: thumbURL}
+}
+
+// NewMiniProgramPageMessage 小程序卡片
+func NewMiniProgramPageMessage(title, pagePath, thumbMediaID string) *MiniProgramPageMessage {
+	return &MiniProgramPageMessage{Title: title, PagePath: pagePath, ThumbMediaID: thumbMediaID}
+}
+
+// MsgType 消息类型
+func (m *TextMessage) MsgType() MsgType { return MsgTypeText }
+
+// MsgType 消息类型
+func (m *ImageMessage) MsgType() MsgType { return MsgTypeImage }
+
+// MsgType 消息类型
+func (m *LinkMessage) MsgType() MsgType { return MsgTypeLink }
+
+// MsgType 消息类型
+func (m *MiniProgramPageMessage) MsgType() MsgType { return MsgTypeMiniProgramPage }
+
+func (m *TextMessage) customerServiceMessage()            {}
+func (m *ImageMessage) customerServiceMessage()           {}
+func (m *LinkMessage) customerServiceMessage()            {}
+func (m *MiniProgramPageMessage) customerServiceMessage() {}
+
+// Validate 校验消息内容
+func (m *TextMessage) Validate() error {
+	return validation.ValidateStruct(m,
+		validation.Field(&m.Content, validation.Required),
+	)
+}
+
+// Validate 校验消息内容
+func (m *ImageMessage) Validate() error {
+	return validation.ValidateStruct(m,
+		validation.Field(&m.MediaID, validation.Required),
+	)
+}
+
+// Validate 校验消息内容
+func (m *LinkMessage) Validate() error {
+	return validation.ValidateStruct(m,
+		validation.Field(&m.Title, validation.Required),
+		validation.Field(&m.Description, validation.Required),
+		validation.Field(&m.URL, validation.Required),
+		validation.Field(&m.ThumbURL, validation.Required),
+	)
+}
+
+// Validate 校验消息内容
+func (m *MiniProgramPageMessage) Validate() error {
+	return validation.ValidateStruct(m,
+		validation.Field(&m.Title, validation.Required),
+		validation.Field(&m.PagePath, validation.Required),
+		validation.Field(&m.ThumbMediaID, validation.Required),
+	)
+}
+
+// SendCustomerServiceMessage 发送客服消息给用户
+// https://developers.weixin.qq.com/miniprogram/dev/api-backend/open-api/customer-message/customerServiceMessage.send.html
+func SendCustomerServiceMessage(accessToken, toUser string, message CustomerServiceMessage) error {
+	return DefaultClient.SendCustomerServiceMessage(accessToken, toUser, message)
+}
+
+// SendCustomerServiceMessageContext 同 SendCustomerServiceMessage，ctx 用于取消请求或设置超时
+func SendCustomerServiceMessageContext(ctx context.Context, accessToken, toUser string, message CustomerServiceMessage) error {
+	return DefaultClient.SendCustomerServiceMessageContext(ctx, accessToken, toUser, message)
+}
+
+// SendCustomerServiceMessage 发送客服消息给用户，toUser 为用户的 openid，accessToken 为空时由凭证管理器提供
+func (c *Client) SendCustomerServiceMessage(accessToken, toUser string, message CustomerServiceMessage) error {
+	return c.SendCustomerServiceMessageContext(context.Background(), accessToken, toUser, message)
+}
+
+// SendCustomerServiceMessageContext 同 SendCustomerServiceMessage，ctx 用于取消请求或设置超时
+func (c *Client) SendCustomerServiceMessageContext(ctx context.Context, accessToken, toUser string, message CustomerServiceMessage) error {
+
+	if err := (validation.Errors{
+		"touser":  validation.Validate(toUser, validation.Required),
+		"message": validation.Validate(message, validation.Required),
+	}).Filter(); err != nil {
+		return errors.Wrap(err, "request param error")
+	}
+
+	URL, err := encodeURL(c.apiURL("/cgi-bin/message/custom/send"), queryParams{"access_token": accessToken})
+	if err != nil {
+		return errors.Wrap(err, "encode url error")
+	}
+
+	request := map[string]interface{}{
+		"touser":                  toUser,
+		"msgtype":                 message.MsgType(),
+		string(message.MsgType()): message,
+	}
+	if err = c.httpPostJSON(ctx, URL, request, nil); err != nil {
+		return errors.Wrap(err, "http request error")
+	}
+	return nil
+}
+
+// TypingCommand 客服输入状态命令
+type TypingCommand string
+
+const (
+	// TypingCommandTyping 对用户下发"正在输入"状态
+	TypingCommandTyping TypingCommand = "Typing"
+	// TypingCommandCancel 取消对用户的"正在输入"状态
+	TypingCommandCancel TypingCommand = "CancelTyping"
+)
+
+// SetTypingRequest 下发客服当前输入状态-请求
+type SetTypingRequest struct {
+	ToUser  string        `json:"touser"`  //用户的 OpenID
+	Command TypingCommand `json:"command"` //命令，Typing 或 CancelTyping
+}
+
+// SetTyping 下发客服当前输入状态给用户
+// https://developers.weixin.qq.com/miniprogram/dev/api-backend/open-api/customer-message/customerServiceMessage.setTyping.html
+func SetTyping(accessToken, toUser string, command TypingCommand) error {
+	return DefaultClient.SetTyping(accessToken, toUser, command)
+}
+
+// SetTypingContext 同 SetTyping，ctx 用于取消请求或设置超时
+func SetTypingContext(ctx context.Context, accessToken, toUser string, command TypingCommand) error {
+	return DefaultClient.SetTypingContext(ctx, accessToken, toUser, command)
+}
+
+// SetTyping 下发客服当前输入状态给用户，accessToken 为空时由凭证管理器提供
+func (c *Client) SetTyping(accessToken, toUser string, command TypingCommand) error {
+	return c.SetTypingContext(context.Background(), accessToken, toUser, command)
+}
+
+// SetTypingContext 同 SetTyping，ctx 用于取消请求或设置超时
+func (c *Client) SetTypingContext(ctx context.Context, accessToken, toUser string, command TypingCommand) error {
+
+	request := &SetTypingRequest{ToUser: toUser, Command: command}
+	if err := validation.ValidateStruct(request,
+		validation.Field(&request.ToUser, validation.Required),
+		validation.Field(&request.Command, validation.Required, validation.In(TypingCommandTyping, TypingCommandCancel)),
+	); err != nil {
+		return errors.Wrap(err, "request param error")
+	}
+
+	URL, err := encodeURL(c.apiURL("/cgi-bin/message/custom/typing"), queryParams{"access_token": accessToken})
+	if err != nil {
+		return errors.Wrap(err, "encode url error")
+	}
+
+	if err = c.httpPostJSON(ctx, URL, request, nil); err != nil {
+		return errors.Wrap(err, "http request error")
+	}
+	return nil
+}
+
+// UploadTempMediaResponse 新增图片素材-响应
+type UploadTempMediaResponse struct {
+	Type      string `json:"type"`       //文件类型
+	MediaID   string `json:"media_id"`   //媒体文件上传后，获取标识，3天内有效
+	CreatedAt int64  `json:"created_at"` //媒体文件上传时间戳
+}
+
+// UploadTempMedia 把媒体文件上传到微信服务器，目前仅支持图片，用于发送客服消息或被动回复用户消息
+// https://developers.weixin.qq.com/miniprogram/dev/api-backend/open-api/customer-message/customerServiceMessage.uploadTempMedia.html
+func UploadTempMedia(accessToken, fileName string, media io.Reader) (*UploadTempMediaResponse, error) {
+	return DefaultClient.UploadTempMedia(accessToken, fileName, media)
+}
+
+// UploadTempMediaContext 同 UploadTempMedia，ctx 用于取消请求或设置超时
+func UploadTempMediaContext(ctx context.Context, accessToken, fileName string, media io.Reader) (*UploadTempMediaResponse, error) {
+	return DefaultClient.UploadTempMediaContext(ctx, accessToken, fileName, media)
+}
+
+// UploadTempMedia 上传图片素材，fileName 的扩展名需与图片格式一致，accessToken 为空时由凭证管理器提供
+func (c *Client) UploadTempMedia(accessToken, fileName string, media io.Reader) (*UploadTempMediaResponse, error) {
+	return c.UploadTempMediaContext(context.Background(), accessToken, fileName, media)
+}
+
+// UploadTempMediaContext 同 UploadTempMedia，ctx 用于取消请求或设置超时
+func (c *Client) UploadTempMediaContext(ctx context.Context, accessToken, fileName string, media io.Reader) (*UploadTempMediaResponse, error) {
+
+	if err := (validation.Errors{
+		"filename": validation.Validate(fileName, validation.Required),
+		"media":    validation.Validate(media, validation.NotNil),
+	}).Filter(); err != nil {
+		return nil, errors.Wrap(err, "request param error")
+	}
+
+	URL, err := encodeURL(c.apiURL("/cgi-bin/media/upload"), queryParams{"access_token": accessToken, "type": "image"})
+	if err != nil {
+		return nil, errors.Wrap(err, "encode url error")
+	}
+
+	fields := []MultipartFormField{{IsFile: true, Name: "media", FileName: fileName, Value: media}}
+	response := new(UploadTempMediaResponse)
+	if err = c.httpPostMultipartForm(ctx, URL, fields, response); err != nil {
+		return nil, errors.Wrap(err, "http request error")
+	}
+	return response, nil
+}
+
+// TempMedia 临时素材
+type TempMedia struct {
+	ContentType string // 响应的 Content-Type，如 image/jpeg
+	FileName    string // Content-Disposition 中的文件名
+	Data        []byte // 文件内容
+}
+
+// decodeMedia 读取非 JSON 的响应体
+func (m *TempMedia) decodeMedia(resp *http.Response) error {
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "read response error")
+	}
+
+	m.ContentType = resp.Header.Get("Content-Type")
+	m.Data = data
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		m.FileName = params["filename"]
+	}
+	return nil
+}
+
+// GetTempMedia 获取客服消息内的临时素材，即下载临时的多媒体文件，目前只支持下载图片
+// https://developers.weixin.qq.com/miniprogram/dev/api-backend/open-api/customer-message/customerServiceMessage.getTempMedia.html
+func GetTempMedia(accessToken, mediaID string) (*TempMedia, error) {
+	return DefaultClient.GetTempMedia(accessToken, mediaID)
+}
+
+// GetTempMediaContext 同 GetTempMedia，ctx 用于取消请求或设置超时
+func GetTempMediaContext(ctx context.Context, accessToken, mediaID string) (*TempMedia, error) {
+	return DefaultClient.GetTempMediaContext(ctx, accessToken, mediaID)
+}
+
+// GetTempMedia 下载临时素材，accessToken 为空时由凭证管理器提供
+func (c *Client) GetTempMedia(accessToken, mediaID string) (*TempMedia, error) {
+	return c.GetTempMediaContext(context.Background(), accessToken, mediaID)
+}
+
+// GetTempMediaContext 同 GetTempMedia，ctx 用于取消请求或设置超时
+func (c *Client) GetTempMediaContext(ctx context.Context, accessToken, mediaID string) (*TempMedia, error) {
+
+	if err := validation.Validate(mediaID, validation.Required); err != nil {
+		return nil, errors.Wrap(err, "request param error")
+	}
+
+	URL, err := encodeURL(c.apiURL("/cgi-bin/media/get"), queryParams{"access_token": accessToken, "media_id": mediaID})
+	if err != nil {
+		return nil, errors.Wrap(err, "encode url error")
+	}
+
+	media := new(TempMedia)
+	if err = c.do(ctx, http.MethodGet, URL, "", nil, media); err != nil {
+		return nil, errors.Wrap(err, "http request error")
+	}
+	return media, nil
+}
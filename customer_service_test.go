@@ -0,0 +1,147 @@
+package wechat
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jayecc/wechat/wechattest"
+	"github.com/pkg/errors"
+)
+
+func TestSendCustomerServiceMessage(t *testing.T) {
+
+	server := wechattest.NewServer()
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithCredential(server.AppID, server.Secret))
+
+	messages := []CustomerServiceMessage{
+		NewTextMessage("Hello World"),
+		NewImageMessage("MEDIA_ID"),
+		NewLinkMessage("Happy Day", "Is Really A Happy Day", "https://example.com/", "https://example.com/thumb.png"),
+		NewMiniProgramPageMessage("title", "pages/index/index?foo=bar", "MEDIA_ID"),
+	}
+	for _, message := range messages {
+		if err := client.SendCustomerServiceMessage("", "OPENID", message); err != nil {
+			t.Fatalf("%s: %v", message.MsgType(), err)
+		}
+		r, _ := server.LastRequest("/cgi-bin/message/custom/send")
+		var body map[string]interface{}
+		if err := r.JSON(&body); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if body["touser"] != "OPENID" || body["msgtype"] != string(message.MsgType()) || body[string(message.MsgType())] == nil {
+			t.Fatalf("body: %s", r.Body)
+		}
+	}
+
+	if err := client.SendCustomerServiceMessage("", "OPENID", NewTextMessage("a<b>&c")); err != nil {
+		t.Fatalf("%v", err)
+	}
+	r, _ := server.LastRequest("/cgi-bin/message/custom/send")
+	if !bytes.Contains(r.Body, []byte(`"text":{"content":"a<b>&c"}`)) {
+		t.Fatalf("body: %s", r.Body)
+	}
+
+	server.Reset()
+	if err := client.SendCustomerServiceMessage("", "OPENID", NewTextMessage("")); err == nil {
+		t.Fatal("empty content should fail validation")
+	}
+	if err := client.SendCustomerServiceMessage("", "", NewTextMessage("Hello")); err == nil {
+		t.Fatal("empty touser should fail validation")
+	}
+	if err := client.SendCustomerServiceMessage("", "OPENID", nil); err == nil {
+		t.Fatal("nil message should fail validation")
+	}
+	if err := client.SendCustomerServiceMessage("", "OPENID", &LinkMessage{Title: "title"}); err == nil {
+		t.Fatal("incomplete link should fail validation")
+	}
+	server.AssertCalled(t, "/cgi-bin/message/custom/send", 0)
+
+	server.FailNext("/cgi-bin/message/custom/send", ErrCodeReplyTimeLimit, "response out of time limit or subscription is canceled")
+	if err := client.SendCustomerServiceMessage("", "OPENID", NewTextMessage("Hello")); !errors.Is(err, ErrReplyTimeLimit) {
+		t.Fatalf("err: %v", err)
+	}
+
+	// 系统繁忙时消息可能已下发，不重试以免用户收到重复消息
+	server.Reset()
+	server.FailNext("/cgi-bin/message/custom/send", ErrCodeSystemBusy, "system error")
+	if err := client.SendCustomerServiceMessage("", "OPENID", NewTextMessage("Hello")); !errors.Is(err, ErrSystemBusy) {
+		t.Fatalf("err: %v", err)
+	}
+	server.AssertCalled(t, "/cgi-bin/message/custom/send", 1)
+}
+
+func TestSetTyping(t *testing.T) {
+
+	server := wechattest.NewServer()
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithCredential(server.AppID, server.Secret))
+
+	for _, command := range []TypingCommand{TypingCommandTyping, TypingCommandCancel} {
+		if err := client.SetTyping("", "OPENID", command); err != nil {
+			t.Fatalf("%s: %v", command, err)
+		}
+		r, _ := server.LastRequest("/cgi-bin/message/custom/typing")
+		var body SetTypingRequest
+		if r.JSON(&body); body.ToUser != "OPENID" || body.Command != command {
+			t.Fatalf("body: %s", r.Body)
+		}
+	}
+
+	if err := client.SetTyping("", "OPENID", "Unknown"); err == nil {
+		t.Fatal("unknown command should fail validation")
+	}
+	server.AssertCalled(t, "/cgi-bin/message/custom/typing", 2)
+}
+
+func TestTempMedia(t *testing.T) {
+
+	server := wechattest.NewServer()
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithCredential(server.AppID, server.Secret))
+
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	uploaded, err := client.UploadTempMedia("", "a.png", bytes.NewReader(png))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if uploaded.Type != "image" || uploaded.MediaID == "" || uploaded.CreatedAt == 0 {
+		t.Fatalf("resp: %+v", uploaded)
+	}
+	server.AssertQuery(t, "/cgi-bin/media/upload", "type", "image")
+	if media, ok := server.Media(uploaded.MediaID); !ok || media.FileName != "a.png" || !bytes.Equal(media.Data, png) {
+		t.Fatalf("media: %+v", media)
+	}
+
+	media, err := client.GetTempMedia("", uploaded.MediaID)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if media.ContentType != "image/png" || media.FileName != "a.png" || !bytes.Equal(media.Data, png) {
+		t.Fatalf("media: %+v", media)
+	}
+	server.AssertQuery(t, "/cgi-bin/media/get", "media_id", uploaded.MediaID)
+
+	if _, err = client.GetTempMedia("", "UNKNOWN"); !errors.Is(err, ErrInvalidMediaID) {
+		t.Fatalf("err: %v", err)
+	}
+
+	// 托管的 access_token 过期后刷新并重放，文件内容需完整重发
+	server.ExpireTokens()
+	if uploaded, err = client.UploadTempMedia("", "b.png", bytes.NewReader(png)); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if media, _ := server.Media(uploaded.MediaID); !bytes.Equal(media.Data, png) {
+		t.Fatalf("media: %+v", media)
+	}
+
+	if _, err = client.UploadTempMedia("", "", bytes.NewReader(png)); err == nil {
+		t.Fatal("empty filename should fail validation")
+	}
+	if _, err = client.GetTempMedia("", ""); err == nil {
+		t.Fatal("empty media_id should fail validation")
+	}
+}
@@ -2,19 +2,162 @@ package wechat
 
 import (
 	"fmt"
+
+	"github.com/pkg/errors"
 )
 
 const (
+	// ErrCodeSystemBusy 系统繁忙，此时请开发者稍候再试
+	ErrCodeSystemBusy = -1
 	// ErrCodeOK 请求成功
 	ErrCodeOK = 0
+	// ErrCodeInvalidCredential 获取 access_token 时 AppSecret 错误，或者 access_token 无效
+	ErrCodeInvalidCredential = 40001
+	// ErrCodeInvalidGrantType 不合法的凭证类型
+	ErrCodeInvalidGrantType = 40002
+	// ErrCodeInvalidOpenID 不合法的 OpenID
+	ErrCodeInvalidOpenID = 40003
+	// ErrCodeInvalidMediaID 不合法的媒体文件 id
+	ErrCodeInvalidMediaID = 40007
+	// ErrCodeInvalidAppID 不合法的 AppID
+	ErrCodeInvalidAppID = 40013
+	// ErrCodeInvalidAccessToken 不合法的 access_token
+	ErrCodeInvalidAccessToken = 40014
+	// ErrCodeInvalidCode code 无效
+	ErrCodeInvalidCode = 40029
+	// ErrCodeInvalidAppSecret 不合法的 AppSecret
+	ErrCodeInvalidAppSecret = 40125
+	// ErrCodeCodeUsed code 已被使用
+	ErrCodeCodeUsed = 40163
+	// ErrCodeRiskyUser 高风险等级用户，小程序登录拦截
+	ErrCodeRiskyUser = 40226
+	// ErrCodeAccessTokenExpired access_token 超时
+	ErrCodeAccessTokenExpired = 42001
+	// ErrCodeAPIUnauthorized api 功能未授权
+	ErrCodeAPIUnauthorized = 48001
+	// ErrCodeDailyLimit 接口调用超过日限额
+	ErrCodeDailyLimit = 45009
+	// ErrCodeFrequencyLimit 调用频率超过限制
+	ErrCodeFrequencyLimit = 45011
+	// ErrCodeReplyTimeLimit 回复时间超过限制，用户 48 小时内未与小程序客服互动时不能下发客服消息
+	ErrCodeReplyTimeLimit = 45015
+	// ErrCodeDateFormat 日期格式错误
+	ErrCodeDateFormat = 61500
+	// ErrCodeDateRange 日期范围错误
+	ErrCodeDateRange = 61501
+	// ErrCodeInvalidSignature 用户登录态签名错误，session_key 已失效或签名计算错误
+	ErrCodeInvalidSignature = 87009
+	// ErrCodeInvalidOrder 订单无效
+	ErrCodeInvalidOrder = 89300
+)
+
+// errCodeText 错误码说明
+var errCodeText = map[int]string{
+	ErrCodeSystemBusy:         "system busy",
+	ErrCodeOK:                 "ok",
+	ErrCodeInvalidCredential:  "invalid credential",
+	ErrCodeInvalidGrantType:   "invalid grant_type",
+	ErrCodeInvalidOpenID:      "invalid openid",
+	ErrCodeInvalidMediaID:     "invalid media_id",
+	ErrCodeInvalidAppID:       "invalid appid",
+	ErrCodeInvalidAccessToken: "invalid access_token",
+	ErrCodeInvalidCode:        "invalid code",
+	ErrCodeInvalidAppSecret:   "invalid appsecret",
+	ErrCodeCodeUsed:           "code been used",
+	ErrCodeRiskyUser:          "risky user",
+	ErrCodeAccessTokenExpired: "access_token expired",
+	ErrCodeAPIUnauthorized:    "api unauthorized",
+	ErrCodeDailyLimit:         "reach max api daily quota limit",
+	ErrCodeFrequencyLimit:     "api freq out of limit",
+	ErrCodeReplyTimeLimit:     "response out of time limit",
+	ErrCodeDateFormat:         "date format error",
+	ErrCodeDateRange:          "date range error",
+	ErrCodeInvalidSignature:   "invalid signature",
+	ErrCodeInvalidOrder:       "invalid order",
+}
+
+// ErrCodeText 错误码说明，未收录的错误码返回空字符串
+func ErrCodeText(code int) string {
+	return errCodeText[code]
+}
+
+// 已知错误，可配合 errors.Is 判断，只比较错误码
+var (
+	ErrSystemBusy         = newError(ErrCodeSystemBusy)
+	ErrInvalidCredential  = newError(ErrCodeInvalidCredential)
+	ErrInvalidOpenID      = newError(ErrCodeInvalidOpenID)
+	ErrInvalidMediaID     = newError(ErrCodeInvalidMediaID)
+	ErrInvalidAppID       = newError(ErrCodeInvalidAppID)
+	ErrInvalidAccessToken = newError(ErrCodeInvalidAccessToken)
+	ErrInvalidCode        = newError(ErrCodeInvalidCode)
+	ErrInvalidAppSecret   = newError(ErrCodeInvalidAppSecret)
+	ErrCodeBeenUsed       = newError(ErrCodeCodeUsed)
+	ErrRiskyUser          = newError(ErrCodeRiskyUser)
+	ErrAccessTokenExpired = newError(ErrCodeAccessTokenExpired)
+	ErrAPIUnauthorized    = newError(ErrCodeAPIUnauthorized)
+	ErrDailyLimit         = newError(ErrCodeDailyLimit)
+	ErrFrequencyLimit     = newError(ErrCodeFrequencyLimit)
+	ErrReplyTimeLimit     = newError(ErrCodeReplyTimeLimit)
+	ErrDateFormat         = newError(ErrCodeDateFormat)
+	ErrDateRange          = newError(ErrCodeDateRange)
+	ErrInvalidSignature   = newError(ErrCodeInvalidSignature)
+	ErrInvalidOrder       = newError(ErrCodeInvalidOrder)
 )
 
 // Error 通用错误
 type Error struct {
-	ErrCode int  `json:"errcode"`
+	ErrCode int    `json:"errcode"`
 	ErrMsg  string `json:"errmsg"`
 }
 
+// newError 根据错误码创建错误
+func newError(code int) *Error {
+	return &Error{ErrCode: code, ErrMsg: errCodeText[code]}
+}
+
 func (err *Error) Error() string {
 	return fmt.Sprintf("errcode: %d, errmsg: %s", err.ErrCode, err.ErrMsg)
 }
+
+// Is 错误码相同即视为同一错误，微信返回的 errmsg 会附带 hint 等信息
+func (err *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.ErrCode == err.ErrCode
+}
+
+// AsError 从错误链中取出微信返回的错误
+func AsError(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// IsRetryable 是否为稍后重试可能成功的错误
+func IsRetryable(err error) bool {
+	e, ok := AsError(err)
+	return ok && e.ErrCode == ErrCodeSystemBusy
+}
+
+// IsTokenExpired 是否为 access_token 失效或过期错误
+func IsTokenExpired(err error) bool {
+	e, ok := AsError(err)
+	if !ok {
+		return false
+	}
+	switch e.ErrCode {
+	case ErrCodeInvalidCredential, ErrCodeInvalidAccessToken, ErrCodeAccessTokenExpired:
+		return true
+	}
+	return false
+}
+
+// IsRateLimited 是否为超过调用频率或日限额错误
+func IsRateLimited(err error) bool {
+	e, ok := AsError(err)
+	if !ok {
+		return false
+	}
+	return e.ErrCode == ErrCodeDailyLimit || e.ErrCode == ErrCodeFrequencyLimit
+}
@@ -0,0 +1,53 @@
+package wechat
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestErrorClassification(t *testing.T) {
+
+	wrap := func(code int, msg string) error {
+		return errors.Wrap(&Error{ErrCode: code, ErrMsg: msg}, "http response code error")
+	}
+
+	tests := []struct {
+		err         error
+		is          error
+		retryable   bool
+		expired     bool
+		rateLimited bool
+	}{
+		{wrap(-1, "system error"), ErrSystemBusy, true, false, false},
+		{wrap(40029, "invalid code, hints: [ req_id: xxx ]"), ErrInvalidCode, false, false, false},
+		{wrap(42001, "access_token expired"), ErrAccessTokenExpired, false, true, false},
+		{wrap(40001, "invalid credential"), ErrInvalidCredential, false, true, false},
+		{wrap(45011, "api minute-quota reach limit"), ErrFrequencyLimit, false, false, true},
+		{wrap(40226, "risky user"), ErrRiskyUser, false, false, false},
+		{wrap(61500, "date format error"), ErrDateFormat, false, false, false},
+		{errors.New("http.Status: 502 Bad Gateway"), nil, false, false, false},
+	}
+
+	for _, tt := range tests {
+		if tt.is != nil && !errors.Is(tt.err, tt.is) {
+			t.Errorf("%v: errors.Is(%v) = false", tt.err, tt.is)
+		}
+		if got := IsRetryable(tt.err); got != tt.retryable {
+			t.Errorf("%v: IsRetryable = %v", tt.err, got)
+		}
+		if got := IsTokenExpired(tt.err); got != tt.expired {
+			t.Errorf("%v: IsTokenExpired = %v", tt.err, got)
+		}
+		if got := IsRateLimited(tt.err); got != tt.rateLimited {
+			t.Errorf("%v: IsRateLimited = %v", tt.err, got)
+		}
+	}
+
+	if errors.Is(wrap(40029, "invalid code"), ErrCodeBeenUsed) {
+		t.Errorf("40029 should not match 40163")
+	}
+	if e, ok := AsError(wrap(40029, "invalid code")); !ok || e.ErrMsg != "invalid code" {
+		t.Errorf("AsError: %v, %v", e, ok)
+	}
+}
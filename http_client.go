@@ -2,9 +2,11 @@ package wechat
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/url"
@@ -63,7 +65,7 @@ func struct2Map(in interface{}, out map[string]string) error {
 }
 
 // httpGetJSON http get request
-func httpGetJSON(clt *http.Client, URL string, request interface{}, response interface{}) error {
+func (c *Client) httpGetJSON(ctx context.Context, URL string, request interface{}, response interface{}) error {
 
 	params := make(map[string]string)
 	if err := struct2Map(request, params); err != nil {
@@ -75,20 +77,11 @@ func httpGetJSON(clt *http.Client, URL string, request interface{}, response int
 		return errors.Wrap(err, "url encode error")
 	}
 
-	httpResp, err := clt.Get(u)
-	if err != nil {
-		return err
-	}
-	defer httpResp.Body.Close()
-
-	if httpResp.StatusCode != http.StatusOK {
-		return fmt.Errorf("http.Status: %s", httpResp.Status)
-	}
-	return decodeJSONResponse(httpResp.Body, response)
+	return c.do(ctx, http.MethodGet, u, "", nil, response)
 }
 
 // httpPostJSON http post request
-func httpPostJSON(clt *http.Client, URL string, request interface{}, response interface{}) error {
+func (c *Client) httpPostJSON(ctx context.Context, URL string, request interface{}, response interface{}) error {
 
 	buffer := textBufferPool.Get().(*bytes.Buffer)
 	buffer.Reset()
@@ -100,16 +93,7 @@ func httpPostJSON(clt *http.Client, URL string, request interface{}, response in
 		return err
 	}
 
-	httpResp, err := clt.Post(URL, "application/json; charset=utf-8", buffer)
-	if err != nil {
-		return err
-	}
-	defer httpResp.Body.Close()
-
-	if httpResp.StatusCode != http.StatusOK {
-		return fmt.Errorf("http.Status: %s", httpResp.Status)
-	}
-	return decodeJSONResponse(httpResp.Body, response)
+	return c.do(ctx, http.MethodPost, URL, "application/json; charset=utf-8", buffer.Bytes(), response)
 }
 
 // MultipartFormField 文件
@@ -121,7 +105,7 @@ type MultipartFormField struct {
 }
 
 // httpPostMultipartForm http post request
-func httpPostMultipartForm(clt *http.Client, URL string, fields []MultipartFormField, response interface{}) error {
+func (c *Client) httpPostMultipartForm(ctx context.Context, URL string, fields []MultipartFormField, response interface{}) error {
 
 	buffer := mediaBufferPool.Get().(*bytes.Buffer)
 	buffer.Reset()
@@ -151,35 +135,181 @@ func httpPostMultipartForm(clt *http.Client, URL string, fields []MultipartFormF
 		return err
 	}
 
-	httpResp, err := clt.Post(URL, multipartWriter.FormDataContentType(), buffer)
+	return c.do(ctx, http.MethodPost, URL, multipartWriter.FormDataContentType(), buffer.Bytes(), response)
+}
+
+// do 发送请求并解析响应。URL 中 access_token 参数为空时由凭证管理器提供，
+// 若微信返回凭证失效则刷新凭证并重放一次请求，body 为已编码的请求体以便重放
+func (c *Client) do(ctx context.Context, method, URL, contentType string, body []byte, response interface{}) error {
+
+	u, err := url.Parse(URL)
+	if err != nil {
+		return errors.Wrap(err, "url parse error")
+	}
+
+	api := c.apiPath(u)
+
+	query := u.Query()
+	if values, ok := query["access_token"]; !ok || len(values) != 1 || values[0] != "" {
+		return c.send(ctx, api, method, URL, contentType, body, response)
+	}
+
+	token, err := c.tokens.TokenContext(ctx)
 	if err != nil {
 		return err
 	}
-	defer httpResp.Body.Close()
+	query.Set("access_token", token)
+	u.RawQuery = query.Encode()
 
-	if httpResp.StatusCode != http.StatusOK {
-		return fmt.Errorf("http.Status: %s", httpResp.Status)
+	err = c.send(ctx, api, method, u.String(), contentType, body, response)
+	if !IsTokenExpired(err) {
+		return err
 	}
-	return decodeJSONResponse(httpResp.Body, response)
+
+	c.tokens.invalidate(token)
+	if token, err = c.tokens.TokenContext(ctx); err != nil {
+		return err
+	}
+	query.Set("access_token", token)
+	u.RawQuery = query.Encode()
+
+	return c.send(ctx, api, method, u.String(), contentType, body, response)
 }
 
-// decodeJSONHttpResponse http json response decode
-func decodeJSONHttpResponse(r io.Reader, v interface{}) error {
-	return json.NewDecoder(r).Decode(v)
+// send 按重试策略发送请求，每次发送前消耗接口配额
+func (c *Client) send(ctx context.Context, api, method, URL, contentType string, body []byte, response interface{}) error {
+
+	idempotent := method == http.MethodGet || method == http.MethodHead
+
+	for attempt := 1; ; attempt++ {
+		if c.limiter != nil {
+			if err := c.limiter.Wait(ctx, c.appID, api); err != nil {
+				return err
+			}
+		}
+
+		err := c.sendOnce(ctx, api, method, URL, contentType, body, response)
+		if c.limiter != nil && IsRateLimited(err) {
+			c.limiter.Drain(c.appID, api, err)
+		}
+		if err == nil || attempt >= c.retry.MaxAttempts || !c.retry.shouldRetry(ctx, err, idempotent) {
+			return err
+		}
+
+		select {
+		case <-time.After(c.retry.backoff(attempt)):
+		case <-ctx.Done():
+			// 等待重试时被取消，返回取消原因并附带最后一次的错误
+			return errors.Wrapf(ctx.Err(), "last attempt: %v", err)
+		}
+	}
 }
 
-// decodeJSONResponse 响应状态码判断
-func decodeJSONResponse(r io.Reader, response interface{}) error {
+// sendOnce 发送一次请求，经过客户端安装的拦截器链
+func (c *Client) sendOnce(ctx context.Context, api, method, URL, contentType string, body []byte, response interface{}) error {
 
-	var errCode Error
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
 
-	if err := decodeJSONHttpResponse(r, &errCode); err != nil {
+	httpReq, err := http.NewRequestWithContext(ctx, method, URL, reader)
+	if err != nil {
 		return err
 	}
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if c.userAgent != "" {
+		httpReq.Header.Set("User-Agent", c.userAgent)
+	}
+
+	call := &Call{API: api, Request: httpReq}
+
+	return c.intercept(call, func(call *Call) error {
+		return c.roundTrip(call, response)
+	})
+}
+
+// roundTrip 发送请求并解析响应，记录耗时与错误码
+func (c *Client) roundTrip(call *Call, response interface{}) error {
+
+	start := time.Now()
+	err := func() error {
+		httpResp, err := c.HTTPClient().Do(call.Request)
+		if err != nil {
+			return err
+		}
+		defer httpResp.Body.Close()
+		call.Response = httpResp
+
+		if httpResp.StatusCode != http.StatusOK {
+			return &HTTPStatusError{StatusCode: httpResp.StatusCode, Status: httpResp.Status}
+		}
+		if media, ok := response.(mediaResponse); ok && !isJSONContentType(httpResp.Header.Get("Content-Type")) {
+			return media.decodeMedia(httpResp)
+		}
+		return decodeJSONResponse(httpResp.Body, response)
+	}()
+	call.Duration = time.Since(start)
+
+	if e, ok := AsError(err); ok {
+		call.ErrCode = e.ErrCode
+	}
+	call.Err = err
+
+	return err
+}
+
+// mediaResponse 可接收文件内容的响应，接口出错时微信仍以 JSON 返回错误码
+type mediaResponse interface {
+	decodeMedia(resp *http.Response) error
+}
+
+// isJSONContentType 响应是否为 JSON，微信部分接口以 text/plain 返回 JSON
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType == ""
+	}
+	return mediaType == "application/json" || mediaType == "text/plain"
+}
+
+// HTTPStatusError 非 200 的http响应
+type HTTPStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (err *HTTPStatusError) Error() string {
+	return fmt.Sprintf("http.Status: %s", err.Status)
+}
+
+// decodeJSONResponse 读取一次响应体，errcode 非 0 时返回 *Error，否则解析到 response
+func decodeJSONResponse(r io.Reader, response interface{}) error {
+
+	buffer := textBufferPool.Get().(*bytes.Buffer)
+	buffer.Reset()
+	defer textBufferPool.Put(buffer)
+
+	if _, err := buffer.ReadFrom(r); err != nil {
+		return errors.Wrap(err, "read response error")
+	}
+
+	var errCode Error
+	if err := json.Unmarshal(buffer.Bytes(), &errCode); err != nil {
+		return errors.Wrap(err, "decode response error")
+	}
 
 	if errCode.ErrCode != ErrCodeOK {
-		return errors.Wrap(errors.New(errCode.Error()), "http response code error")
+		return errors.Wrap(&errCode, "http response code error")
 	}
 
-	return decodeJSONHttpResponse(r, response)
+	if response == nil {
+		return nil
+	}
+	if err := json.Unmarshal(buffer.Bytes(), response); err != nil {
+		return errors.Wrap(err, "decode response error")
+	}
+	return nil
 }
@@ -0,0 +1,204 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestTokenInvalidReplay(t *testing.T) {
+
+	var tokens, bodies []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		tokens = append(tokens, r.URL.Query().Get("access_token"))
+		bodies = append(bodies, string(body))
+		fmt.Fprint(w, `{"errcode":40001,"errmsg":"invalid credential"}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL))
+
+	fetches := 0
+	client.Tokens().fetch = func(ctx context.Context) (string, time.Duration, error) {
+		fetches++
+		return fmt.Sprintf("token%d", fetches), time.Hour, nil
+	}
+
+	req := &GetDatacubeRequest{BeginDate: "20170313", EndDate: "20170313"}
+	err := client.GetDatacube(GetDailySummary, "", req, new(GetDailySummaryResponse))
+	if !IsTokenExpired(err) {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(tokens) != 2 || tokens[0] != "token1" || tokens[1] != "token2" {
+		t.Fatalf("tokens: %v", tokens)
+	}
+	if bodies[0] == "" || bodies[0] != bodies[1] {
+		t.Fatalf("bodies: %q", bodies)
+	}
+
+	tokens = nil
+	err = client.GetPaidUnionID(&GetPaidUnionIDRequest{AccessToken: "explicit", Openid: "openid"}, new(GetPaidUnionIDResponse))
+	if !IsTokenExpired(err) || len(tokens) != 1 || tokens[0] != "explicit" {
+		t.Fatalf("err: %v, tokens: %v", err, tokens)
+	}
+}
+
+func TestContextCanceled(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithCredential("appid", "secret"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := client.Code2SessionContext(ctx, &Code2SessionRequest{JsCode: "code"}, new(Code2SessionResponse))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestAPIResponses(t *testing.T) {
+
+	datacubeReq := func() *GetDatacubeRequest {
+		return &GetDatacubeRequest{BeginDate: "20170313", EndDate: "20170313"}
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		call    func(c *Client) (interface{}, error)
+		want    interface{}
+		errCode int
+		wantErr bool
+	}{
+		{
+			name: "code2session success",
+			path: "/sns/jscode2session",
+			body: `{"openid":"openid","session_key":"key","unionid":"unionid","errcode":0,"errmsg":""}`,
+			call: func(c *Client) (interface{}, error) {
+				resp := new(Code2SessionResponse)
+				return resp, c.Code2Session(&Code2SessionRequest{JsCode: "code"}, resp)
+			},
+			want: &Code2SessionResponse{OpenID: "openid", SessionKey: "key", UnionID: "unionid"},
+		},
+		{
+			name: "code2session errcode",
+			path: "/sns/jscode2session",
+			body: `{"errcode":40029,"errmsg":"invalid code"}`,
+			call: func(c *Client) (interface{}, error) {
+				resp := new(Code2SessionResponse)
+				return resp, c.Code2Session(&Code2SessionRequest{JsCode: "code"}, resp)
+			},
+			errCode: ErrCodeInvalidCode,
+		},
+		{
+			name: "get access token success",
+			path: "/cgi-bin/token",
+			body: `{"access_token":"token","expires_in":7200}`,
+			call: func(c *Client) (interface{}, error) {
+				resp := new(GetAccessTokenResponse)
+				return resp, c.GetAccessToken(new(GetAccessTokenRequest), resp)
+			},
+			want: &GetAccessTokenResponse{AccessToken: "token", ExpiresIn: 7200},
+		},
+		{
+			name: "get access token malformed json",
+			path: "/cgi-bin/token",
+			body: `{"access_token":`,
+			call: func(c *Client) (interface{}, error) {
+				resp := new(GetAccessTokenResponse)
+				return resp, c.GetAccessToken(new(GetAccessTokenRequest), resp)
+			},
+			wantErr: true,
+		},
+		{
+			name: "get paid unionid success",
+			path: "/wxa/getpaidunionid",
+			body: `{"unionid":"unionid","errcode":0,"errmsg":"ok"}`,
+			call: func(c *Client) (interface{}, error) {
+				resp := new(GetPaidUnionIDResponse)
+				return resp, c.GetPaidUnionID(&GetPaidUnionIDRequest{AccessToken: "token", Openid: "openid"}, resp)
+			},
+			want: &GetPaidUnionIDResponse{UnionID: "unionid"},
+		},
+		{
+			name: "datacube success",
+			path: "/datacube/getweanalysisappiddailysummarytrend",
+			body: `{"list":[{"ref_date":"20170313","visit_total":391,"share_pv":572,"share_uv":383}]}`,
+			call: func(c *Client) (interface{}, error) {
+				resp := new(GetDailySummaryResponse)
+				return resp, c.GetDatacube(GetDailySummary, "token", datacubeReq(), resp)
+			},
+			want: &GetDailySummaryResponse{List: []DailySummary{{RefDate: "20170313", VisitTotal: 391, SharePV: 572, ShareUV: 383}}},
+		},
+		{
+			name: "datacube errcode",
+			path: "/datacube/getweanalysisappiddailysummarytrend",
+			body: `{"errcode":61500,"errmsg":"date format error"}`,
+			call: func(c *Client) (interface{}, error) {
+				resp := new(GetDailySummaryResponse)
+				return resp, c.GetDatacube(GetDailySummary, "token", datacubeReq(), resp)
+			},
+			errCode: ErrCodeDateFormat,
+		},
+		{
+			name: "datacube malformed json",
+			path: "/datacube/getweanalysisappiddailysummarytrend",
+			body: `<html>502 Bad Gateway</html>`,
+			call: func(c *Client) (interface{}, error) {
+				resp := new(GetDailySummaryResponse)
+				return resp, c.GetDatacube(GetDailySummary, "token", datacubeReq(), resp)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.path {
+					t.Errorf("path: %s, want %s", r.URL.Path, tt.path)
+				}
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			client := NewClient(WithBaseURL(server.URL), WithCredential("appid", "secret"))
+			got, err := tt.call(client)
+
+			switch {
+			case tt.errCode != 0:
+				if e, ok := AsError(err); !ok || e.ErrCode != tt.errCode {
+					t.Fatalf("err: %v, want errcode %d", err, tt.errCode)
+				}
+			case tt.wantErr:
+				if err == nil {
+					t.Fatalf("want error")
+				}
+			default:
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("got %+v, want %+v", got, tt.want)
+				}
+			}
+		})
+	}
+}
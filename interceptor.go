@@ -0,0 +1,162 @@
+package wechat
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Call 一次http请求的调用信息，在拦截器链中传递
+type Call struct {
+	API      string         // 接口路径，如 /sns/jscode2session
+	Request  *http.Request  // 请求，进入下一环前可修改请求头等
+	Response *http.Response // 响应，请求未发出或网络错误时为 nil，Body 已被读取
+	Duration time.Duration  // 请求与解析响应的耗时
+	ErrCode  int            // 微信返回的 errcode
+	Err      error          // 调用结果
+}
+
+// Handler 执行调用
+type Handler func(call *Call) error
+
+// Interceptor 拦截器，在调用 next 前后观察或修改调用，可用于日志、监控、链路追踪
+type Interceptor func(call *Call, next Handler) error
+
+// intercept 依次经过拦截器后执行 handler
+func (c *Client) intercept(call *Call, handler Handler) error {
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := c.interceptors[i], handler
+		handler = func(call *Call) error {
+			return interceptor(call, next)
+		}
+	}
+	return handler(call)
+}
+
+// redactedParams 日志中需要脱敏的参数
+var redactedParams = []string{"access_token", "secret", "js_code", "session_key", "signature"}
+
+// redact 脱敏 URL 中的凭证参数，并从 text 中抹去这些参数值
+func redact(u *url.URL, text string) (string, string) {
+
+	redacted := *u
+	query := redacted.Query()
+	for _, name := range redactedParams {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		query.Set(name, "REDACTED")
+		text = strings.Replace(text, url.QueryEscape(value), "REDACTED", -1)
+		text = strings.Replace(text, value, "REDACTED", -1)
+	}
+	redacted.RawQuery = query.Encode()
+
+	return redacted.String(), text
+}
+
+// Logger 日志输出，*log.Logger 即满足
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// LoggingInterceptor 每次调用输出一行 key=value 格式日志，access_token、secret 等参数已脱敏
+func LoggingInterceptor(logger Logger) Interceptor {
+	return func(call *Call, next Handler) error {
+
+		err := next(call)
+
+		status := 0
+		if call.Response != nil {
+			status = call.Response.StatusCode
+		}
+		errText := ""
+		if err != nil {
+			errText = err.Error()
+		}
+		URL, errText := redact(call.Request.URL, errText)
+
+		logger.Printf("wechat api=%s method=%s url=%q status=%d errcode=%d duration=%s err=%q",
+			call.API, call.Request.Method, URL, status, call.ErrCode, call.Duration, errText)
+
+		return err
+	}
+}
+
+// APIStats 接口调用统计
+type APIStats struct {
+	Calls         int64         // 调用次数
+	Errors        int64         // 失败次数
+	ErrCodes      map[int]int64 // 各 errcode 出现次数
+	TotalDuration time.Duration // 累计耗时
+	MaxDuration   time.Duration // 最大耗时
+}
+
+// AvgDuration 平均耗时
+func (s APIStats) AvgDuration() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.TotalDuration / time.Duration(s.Calls)
+}
+
+// Metrics 按接口统计调用次数、失败次数与耗时
+type Metrics struct {
+	mu    sync.Mutex
+	stats map[string]*APIStats
+}
+
+// NewMetrics 创建统计
+func NewMetrics() *Metrics {
+	return &Metrics{stats: make(map[string]*APIStats)}
+}
+
+// Interceptor 统计拦截器
+func (m *Metrics) Interceptor() Interceptor {
+	return func(call *Call, next Handler) error {
+
+		err := next(call)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		s, ok := m.stats[call.API]
+		if !ok {
+			s = &APIStats{ErrCodes: make(map[int]int64)}
+			m.stats[call.API] = s
+		}
+		s.Calls++
+		if err != nil {
+			s.Errors++
+		}
+		if call.ErrCode != ErrCodeOK {
+			s.ErrCodes[call.ErrCode]++
+		}
+		s.TotalDuration += call.Duration
+		if call.Duration > s.MaxDuration {
+			s.MaxDuration = call.Duration
+		}
+
+		return err
+	}
+}
+
+// Snapshot 当前统计的副本，键为接口路径
+func (m *Metrics) Snapshot() map[string]APIStats {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[string]APIStats, len(m.stats))
+	for api, s := range m.stats {
+		copied := *s
+		copied.ErrCodes = make(map[int]int64, len(s.ErrCodes))
+		for code, n := range s.ErrCodes {
+			copied.ErrCodes[code] = n
+		}
+		snapshot[api] = copied
+	}
+	return snapshot
+}
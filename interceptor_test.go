@@ -0,0 +1,64 @@
+package wechat
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInterceptors(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Trace-Id") != "trace" {
+			t.Errorf("missing trace header")
+		}
+		fmt.Fprint(w, `{"errcode":40029,"errmsg":"invalid code"}`)
+	}))
+	defer server.Close()
+
+	var order []string
+	trace := func(call *Call, next Handler) error {
+		order = append(order, "trace")
+		call.Request.Header.Set("X-Trace-Id", "trace")
+		return next(call)
+	}
+
+	var buf bytes.Buffer
+	metrics := NewMetrics()
+
+	client := NewClient(
+		WithBaseURL(server.URL),
+		WithCredential("appid", "app-secret"),
+		WithInterceptors(trace, LoggingInterceptor(log.New(&buf, "", 0)), metrics.Interceptor()),
+	)
+
+	err := client.Code2Session(&Code2SessionRequest{JsCode: "login-code"}, new(Code2SessionResponse))
+	if err == nil {
+		t.Fatalf("want error")
+	}
+
+	if len(order) != 1 {
+		t.Fatalf("order: %v", order)
+	}
+
+	line := buf.String()
+	for _, want := range []string{"api=/sns/jscode2session", "method=GET", "status=200", "errcode=40029"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("log %q missing %q", line, want)
+		}
+	}
+	for _, secret := range []string{"app-secret", "login-code"} {
+		if strings.Contains(line, secret) {
+			t.Errorf("log %q leaks %q", line, secret)
+		}
+	}
+
+	stats := metrics.Snapshot()["/sns/jscode2session"]
+	if stats.Calls != 1 || stats.Errors != 1 || stats.ErrCodes[40029] != 1 {
+		t.Fatalf("stats: %+v", stats)
+	}
+}
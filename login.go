@@ -0,0 +1,143 @@
+package wechat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// LoginSession 登录会话，持有 code2Session 返回的用户标识与会话密钥。
+// 会话密钥不导出，仅用于签名校验与开放数据解密，避免在业务代码中传递或输出
+type LoginSession struct {
+	OpenID  string // 用户唯一标识
+	UnionID string // 用户在开放平台的唯一标识符，满足 UnionID 下发条件时返回
+
+	mu         sync.RWMutex // 保护 sessionKey，重置密钥时可能有其他请求正在使用
+	sessionKey string
+	client     *Client
+}
+
+// Login 使用 wx.login 获取的 code 登录，返回登录会话
+func Login(jsCode string) (*LoginSession, error) {
+	return DefaultClient.Login(jsCode)
+}
+
+// LoginContext 同 Login，ctx 用于取消请求或设置超时
+func LoginContext(ctx context.Context, jsCode string) (*LoginSession, error) {
+	return DefaultClient.LoginContext(ctx, jsCode)
+}
+
+// Login 使用 wx.login 获取的 code 登录，返回登录会话
+func (c *Client) Login(jsCode string) (*LoginSession, error) {
+	return c.LoginContext(context.Background(), jsCode)
+}
+
+// LoginContext 同 Login，ctx 用于取消请求或设置超时
+func (c *Client) LoginContext(ctx context.Context, jsCode string) (*LoginSession, error) {
+	resp := new(Code2SessionResponse)
+	if err := c.Code2SessionContext(ctx, &Code2SessionRequest{JsCode: jsCode}, resp); err != nil {
+		return nil, err
+	}
+	return c.NewLoginSession(resp), nil
+}
+
+// NewLoginSession 由 code2Session 的响应创建登录会话，如从存储中恢复的会话
+func (c *Client) NewLoginSession(resp *Code2SessionResponse) *LoginSession {
+	return &LoginSession{
+		OpenID:     resp.OpenID,
+		UnionID:    resp.UnionID,
+		sessionKey: resp.SessionKey,
+		client:     c,
+	}
+}
+
+// response 会话对应的 code2Session 响应，用于保存到存储
+func (s *LoginSession) response() *Code2SessionResponse {
+	return &Code2SessionResponse{OpenID: s.OpenID, SessionKey: s.key(), UnionID: s.UnionID}
+}
+
+// key 当前的会话密钥
+func (s *LoginSession) key() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sessionKey
+}
+
+// String 会话信息，不含会话密钥
+func (s *LoginSession) String() string {
+	return fmt.Sprintf("LoginSession{OpenID:%s UnionID:%s}", s.OpenID, s.UnionID)
+}
+
+// GoString 同 String，避免 %#v 输出会话密钥
+func (s *LoginSession) GoString() string {
+	return s.String()
+}
+
+// VerifyUserInfo 校验 wx.getUserInfo 返回的 rawData 与 signature，通过后返回 rawData 中的用户信息
+func (s *LoginSession) VerifyUserInfo(rawData, signature string) (*UserInfo, error) {
+
+	if !VerifyUserInfoSignature(rawData, signature, s.key()) {
+		return nil, ErrSignatureMismatch
+	}
+
+	info := new(UserInfo)
+	if err := json.Unmarshal([]byte(rawData), info); err != nil {
+		return nil, errors.Wrap(err, "raw data decode error")
+	}
+	return info, nil
+}
+
+// DecryptUserData 使用会话密钥解密开放数据并校验水印，结果解析到 v
+func (s *LoginSession) DecryptUserData(encryptedData, iv string, v interface{}) error {
+	return s.client.DecryptUserData(s.key(), encryptedData, iv, v)
+}
+
+// DecryptUserInfo 解密用户信息
+func (s *LoginSession) DecryptUserInfo(encryptedData, iv string) (*UserInfo, error) {
+	return s.client.DecryptUserInfo(s.key(), encryptedData, iv)
+}
+
+// DecryptPhoneNumber 解密用户手机号
+func (s *LoginSession) DecryptPhoneNumber(encryptedData, iv string) (*PhoneInfo, error) {
+	return s.client.DecryptPhoneNumber(s.key(), encryptedData, iv)
+}
+
+// DecryptShareInfo 解密转发信息
+func (s *LoginSession) DecryptShareInfo(encryptedData, iv string) (*ShareInfo, error) {
+	return s.client.DecryptShareInfo(s.key(), encryptedData, iv)
+}
+
+// DecryptRunData 解密微信运动步数
+func (s *LoginSession) DecryptRunData(encryptedData, iv string) (*RunData, error) {
+	return s.client.DecryptRunData(s.key(), encryptedData, iv)
+}
+
+// CheckSessionKey 校验会话密钥是否仍然有效，失效时返回 ErrInvalidSignature，需重新登录
+func (s *LoginSession) CheckSessionKey() error {
+	return s.CheckSessionKeyContext(context.Background())
+}
+
+// CheckSessionKeyContext 同 CheckSessionKey，ctx 用于取消请求或设置超时
+func (s *LoginSession) CheckSessionKeyContext(ctx context.Context) error {
+	return s.client.CheckSessionKeyContext(ctx, "", s.OpenID, s.key())
+}
+
+// ResetSessionKey 重置会话密钥，成功后会话使用新的密钥
+func (s *LoginSession) ResetSessionKey() error {
+	return s.ResetSessionKeyContext(context.Background())
+}
+
+// ResetSessionKeyContext 同 ResetSessionKey，ctx 用于取消请求或设置超时
+func (s *LoginSession) ResetSessionKeyContext(ctx context.Context) error {
+	resp, err := s.client.ResetUserSessionKeyContext(ctx, "", s.OpenID, s.key())
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.sessionKey = resp.SessionKey
+	s.mu.Unlock()
+	return nil
+}
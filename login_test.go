@@ -0,0 +1,108 @@
+package wechat
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jayecc/wechat/wechattest"
+	"github.com/pkg/errors"
+)
+
+func TestLoginSession(t *testing.T) {
+
+	sessionKey := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	iv := base64.StdEncoding.EncodeToString([]byte("fedcba9876543210"))
+
+	server := wechattest.NewServer()
+	defer server.Close()
+	server.AddCode("CODE", wechattest.Session{OpenID: "OPENID", SessionKey: sessionKey, UnionID: "UNIONID"})
+
+	client := NewClient(WithBaseURL(server.URL), WithCredential(server.AppID, server.Secret))
+
+	session, err := client.Login("CODE")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if session.OpenID != "OPENID" || session.UnionID != "UNIONID" {
+		t.Fatalf("session: %v", session)
+	}
+	for _, s := range []string{fmt.Sprint(session), fmt.Sprintf("%+v", session), fmt.Sprintf("%#v", session)} {
+		if strings.Contains(s, sessionKey) {
+			t.Fatalf("session key leaked: %s", s)
+		}
+	}
+
+	rawData := `{"nickName":"Band","gender":1,"language":"zh_CN","city":"Guangzhou","province":"Guangdong","country":"CN","avatarUrl":"http://wx.qlogo.cn/mmopen/vi_32/1vZvI39NWFQ9XM4LtQpFrQJ1xlgZxx3w7bQxKARol6503Iuswjjn6nIGBiaycAjAtpujxyzYsrztuuICqIM5ibXQ/0"}`
+	sum := sha1.Sum([]byte(rawData + sessionKey))
+	signature := hex.EncodeToString(sum[:])
+
+	if !VerifyUserInfoSignature(rawData, strings.ToUpper(signature), sessionKey) {
+		t.Fatal("signature should match")
+	}
+	info, err := session.VerifyUserInfo(rawData, signature)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if info.NickName != "Band" || info.Province != "Guangdong" {
+		t.Fatalf("info: %+v", info)
+	}
+	if _, err = session.VerifyUserInfo(strings.Replace(rawData, "Band", "Bond", 1), signature); !errors.Is(err, ErrSignatureMismatch) {
+		t.Fatalf("err: %v", err)
+	}
+
+	data := encryptUserData(t, sessionKey, iv, fmt.Sprintf(`{"phoneNumber":"13800000000","watermark":{"appid":%q,"timestamp":%d}}`, server.AppID, time.Now().Unix()))
+	if phone, err := session.DecryptPhoneNumber(data, iv); err != nil || phone.PhoneNumber != "13800000000" {
+		t.Fatalf("phone: %+v %v", phone, err)
+	}
+
+	restored := client.NewLoginSession(&Code2SessionResponse{OpenID: "OPENID", SessionKey: sessionKey})
+	if _, err = restored.VerifyUserInfo(rawData, signature); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if _, err = client.Login("CODE"); !errors.Is(err, ErrCodeBeenUsed) {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestLoginSessionResetConcurrent(t *testing.T) {
+
+	sessionKey := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+
+	server := wechattest.NewServer()
+	defer server.Close()
+	server.AddCode("CODE", wechattest.Session{OpenID: "OPENID", SessionKey: sessionKey})
+
+	client := NewClient(WithBaseURL(server.URL), WithCredential(server.AppID, server.Secret))
+
+	session, err := client.Login("CODE")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// 重置密钥时其他请求仍在使用会话，需配合 -race 运行
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				session.VerifyUserInfo(`{"nickName":"Band"}`, "signature")
+			}
+		}()
+	}
+	if err = session.ResetSessionKey(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	wg.Wait()
+
+	if session.key() == sessionKey {
+		t.Fatal("session key not reset")
+	}
+}
@@ -0,0 +1,56 @@
+package wechat
+
+import (
+	"context"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+// GetUserPhoneNumberRequest 获取手机号-请求
+type GetUserPhoneNumberRequest struct {
+	Code string `json:"code"` //手机号获取凭证，getPhoneNumber 事件回调返回的 code
+}
+
+// GetUserPhoneNumberResponse 获取手机号-响应
+type GetUserPhoneNumberResponse struct {
+	PhoneInfo PhoneInfo `json:"phone_info"` //用户手机号信息
+}
+
+// GetUserPhoneNumber 使用 getPhoneNumber 返回的 code 换取用户手机号，每个 code 只能使用一次，有效期为 5 分钟
+// https://developers.weixin.qq.com/miniprogram/dev/OpenApiDoc/user-info/phone-number/getPhoneNumber.html
+func GetUserPhoneNumber(accessToken, code string) (*PhoneInfo, error) {
+	return DefaultClient.GetUserPhoneNumber(accessToken, code)
+}
+
+// GetUserPhoneNumberContext 同 GetUserPhoneNumber，ctx 用于取消请求或设置超时
+func GetUserPhoneNumberContext(ctx context.Context, accessToken, code string) (*PhoneInfo, error) {
+	return DefaultClient.GetUserPhoneNumberContext(ctx, accessToken, code)
+}
+
+// GetUserPhoneNumber 使用 getPhoneNumber 返回的 code 换取用户手机号，accessToken 为空时由凭证管理器提供
+func (c *Client) GetUserPhoneNumber(accessToken, code string) (*PhoneInfo, error) {
+	return c.GetUserPhoneNumberContext(context.Background(), accessToken, code)
+}
+
+// GetUserPhoneNumberContext 同 GetUserPhoneNumber，ctx 用于取消请求或设置超时
+func (c *Client) GetUserPhoneNumberContext(ctx context.Context, accessToken, code string) (*PhoneInfo, error) {
+
+	request := &GetUserPhoneNumberRequest{Code: code}
+	if err := validation.ValidateStruct(request,
+		validation.Field(&request.Code, validation.Required),
+	); err != nil {
+		return nil, errors.Wrap(err, "request param error")
+	}
+
+	URL, err := encodeURL(c.apiURL("/wxa/business/getuserphonenumber"), queryParams{"access_token": accessToken})
+	if err != nil {
+		return nil, errors.Wrap(err, "encode url error")
+	}
+
+	response := new(GetUserPhoneNumberResponse)
+	if err = c.httpPostJSON(ctx, URL, request, response); err != nil {
+		return nil, errors.Wrap(err, "http request error")
+	}
+	return &response.PhoneInfo, nil
+}
@@ -0,0 +1,40 @@
+package wechat
+
+import (
+	"testing"
+
+	"github.com/jayecc/wechat/wechattest"
+	"github.com/pkg/errors"
+)
+
+func TestGetUserPhoneNumber(t *testing.T) {
+
+	server := wechattest.NewServer()
+	defer server.Close()
+	server.AddPhoneCode("PHONECODE", wechattest.Phone{PhoneNumber: "+86 13800000000", PurePhoneNumber: "13800000000", CountryCode: "86"})
+
+	client := NewClient(WithBaseURL(server.URL), WithCredential(server.AppID, server.Secret))
+
+	phone, err := client.GetUserPhoneNumber("", "PHONECODE")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if phone.PhoneNumber != "+86 13800000000" || phone.PurePhoneNumber != "13800000000" || phone.CountryCode != "86" ||
+		phone.Watermark.AppID != server.AppID || phone.Watermark.Timestamp == 0 {
+		t.Fatalf("phone: %+v", phone)
+	}
+
+	r, _ := server.LastRequest("/wxa/business/getuserphonenumber")
+	var body GetUserPhoneNumberRequest
+	if err = r.JSON(&body); err != nil || body.Code != "PHONECODE" || r.Query.Get("access_token") == "" {
+		t.Fatalf("request: %+v %v", r, err)
+	}
+
+	if _, err = client.GetUserPhoneNumber("", "PHONECODE"); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err = client.GetUserPhoneNumber("", ""); err == nil {
+		t.Fatal("empty code should fail")
+	}
+	server.AssertCalled(t, "/wxa/business/getuserphonenumber", 2)
+}
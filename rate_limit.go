@@ -0,0 +1,239 @@
+package wechat
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrQuotaExhausted 本地配额已用尽，请求未发出
+var ErrQuotaExhausted = errors.New("api quota exhausted")
+
+// Quota 接口调用配额，Per 周期内最多调用 Limit 次
+type Quota struct {
+	Limit int
+	Per   time.Duration
+}
+
+// DefaultQuotas 默认配额，键为接口路径。
+// access_token 每日 2000 次为微信文档限制，其余为保守取值，实际配额以公众平台「开发 - 接口设置」为准
+var DefaultQuotas = map[string]Quota{
+	"/cgi-bin/token":      {Limit: 2000, Per: 24 * time.Hour},
+	"/sns/jscode2session": {Limit: 5000, Per: time.Minute},
+	"/wxa/getpaidunionid": {Limit: 5000, Per: time.Minute},
+
+	"/wxa/checksession":        {Limit: 5000, Per: time.Minute},
+	"/wxa/resetusersessionkey": {Limit: 5000, Per: time.Minute},
+
+	"/wxa/business/getuserphonenumber": {Limit: 5000, Per: time.Minute},
+
+	"/cgi-bin/message/custom/send":   {Limit: 5000, Per: time.Minute},
+	"/cgi-bin/message/custom/typing": {Limit: 5000, Per: time.Minute},
+	"/cgi-bin/media/upload":          {Limit: 1000, Per: time.Minute},
+	"/cgi-bin/media/get":             {Limit: 5000, Per: time.Minute},
+
+	"/datacube/getweanalysisappiddailyretaininfo":   {Limit: 5000, Per: 24 * time.Hour},
+	"/datacube/getweanalysisappidmonthlyretaininfo": {Limit: 5000, Per: 24 * time.Hour},
+	"/datacube/getweanalysisappidweeklyretaininfo":  {Limit: 5000, Per: 24 * time.Hour},
+	"/datacube/getweanalysisappiddailysummarytrend": {Limit: 5000, Per: 24 * time.Hour},
+	"/datacube/getweanalysisappiddailyvisittrend":   {Limit: 5000, Per: 24 * time.Hour},
+	"/datacube/getweanalysisappidmonthlyvisittrend": {Limit: 5000, Per: 24 * time.Hour},
+	"/datacube/getweanalysisappidweeklyvisittrend":  {Limit: 5000, Per: 24 * time.Hour},
+	"/datacube/getweanalysisappiduserportrait":      {Limit: 5000, Per: 24 * time.Hour},
+	"/datacube/getweanalysisappidvisitdistribution": {Limit: 5000, Per: 24 * time.Hour},
+	"/datacube/getweanalysisappidvisitpage":         {Limit: 5000, Per: 24 * time.Hour},
+}
+
+// quotaResetLocation 微信按北京时间零点重置每日配额
+var quotaResetLocation = time.FixedZone("CST", 8*60*60)
+
+// RateLimiter 按小程序与接口路径限流的令牌桶，多个客户端可共用，未配置配额的接口不限流
+type RateLimiter struct {
+	mu          sync.Mutex
+	quotas      map[string]Quota
+	buckets     map[string]*bucket
+	maxWait     time.Duration
+	onExhausted func(api string, quota Quota)
+	now         func() time.Time
+}
+
+// bucket 令牌桶
+type bucket struct {
+	tokens  float64
+	last    time.Time
+	blocked time.Time // 日配额用尽后停止发放令牌直至该时间
+}
+
+// RateLimiterOption 限流配置项
+type RateLimiterOption func(*RateLimiter)
+
+// WithQuota 设置接口配额，Limit 小于等于 0 时取消该接口的限流
+func WithQuota(api string, quota Quota) RateLimiterOption {
+	return func(l *RateLimiter) {
+		if quota.Limit <= 0 || quota.Per <= 0 {
+			delete(l.quotas, api)
+			return
+		}
+		l.quotas[api] = quota
+	}
+}
+
+// WithMaxWait 设置令牌不足时的最长等待时间，超过则直接返回 ErrQuotaExhausted，默认不等待
+func WithMaxWait(d time.Duration) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.maxWait = d
+	}
+}
+
+// WithQuotaExhaustedHook 设置配额用尽时的回调，本地令牌不足或微信返回 45009/45011 时触发
+func WithQuotaExhaustedHook(fn func(api string, quota Quota)) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.onExhausted = fn
+	}
+}
+
+// NewRateLimiter 创建限流器，默认使用 DefaultQuotas
+func NewRateLimiter(opts ...RateLimiterOption) *RateLimiter {
+	l := &RateLimiter{
+		quotas:  make(map[string]Quota, len(DefaultQuotas)),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+	for api, quota := range DefaultQuotas {
+		l.quotas[api] = quota
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Wait 消耗小程序接口的一个令牌，令牌不足时最多等待 maxWait，否则返回 ErrQuotaExhausted
+func (l *RateLimiter) Wait(ctx context.Context, appID, api string) error {
+
+	l.mu.Lock()
+	quota, ok := l.quotas[api]
+	if !ok {
+		l.mu.Unlock()
+		return nil
+	}
+
+	now := l.now()
+	b := l.refill(bucketKey(appID, api), quota, now)
+	if now.Before(b.blocked) {
+		wait := b.blocked.Sub(now)
+		l.mu.Unlock()
+		if wait > l.maxWait {
+			l.exhausted(api, quota)
+			return errors.Wrap(ErrQuotaExhausted, api)
+		}
+		select {
+		case <-time.After(wait):
+			return l.Wait(ctx, appID, api)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		l.mu.Unlock()
+		return nil
+	}
+
+	rate := float64(quota.Limit) / float64(quota.Per)
+	wait := time.Duration((1 - b.tokens) / rate)
+	if wait > l.maxWait {
+		l.mu.Unlock()
+		l.exhausted(api, quota)
+		return errors.Wrap(ErrQuotaExhausted, api)
+	}
+	b.tokens--
+	l.mu.Unlock()
+
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		b.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Drain 清空小程序接口的令牌，微信返回超出配额时调用，避免继续无效请求。
+// err 为 45009 日限额时直至次日零点（北京时间）配额重置前不再发放令牌，45011 等频率限制按速率恢复。
+// 未配置配额的接口不做处理；已为排队请求预留的令牌（负数余额）保持不变
+func (l *RateLimiter) Drain(appID, api string, err error) {
+
+	l.mu.Lock()
+	quota, ok := l.quotas[api]
+	if !ok {
+		l.mu.Unlock()
+		return
+	}
+	now := l.now()
+	b := l.refill(bucketKey(appID, api), quota, now)
+	if b.tokens > 0 {
+		b.tokens = 0
+	}
+	if errors.Is(err, ErrDailyLimit) {
+		b.blocked = nextQuotaReset(now)
+	}
+	l.mu.Unlock()
+
+	l.exhausted(api, quota)
+}
+
+// refill 按时间补充令牌，调用方需持有锁
+func (l *RateLimiter) refill(key string, quota Quota, now time.Time) *bucket {
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(quota.Limit), last: now}
+		l.buckets[key] = b
+		return b
+	}
+
+	if !b.blocked.IsZero() {
+		if now.Before(b.blocked) {
+			b.last = now
+			return b
+		}
+		// 日配额已重置，保留为排队请求预留的令牌
+		b.tokens = float64(quota.Limit) + math.Min(b.tokens, 0)
+		b.last = now
+		b.blocked = time.Time{}
+		return b
+	}
+
+	rate := float64(quota.Limit) / float64(quota.Per)
+	b.tokens += float64(now.Sub(b.last)) * rate
+	if b.tokens > float64(quota.Limit) {
+		b.tokens = float64(quota.Limit)
+	}
+	b.last = now
+	return b
+}
+
+// bucketKey 令牌桶的键，配额按小程序分别计算
+func bucketKey(appID, api string) string {
+	return appID + " " + api
+}
+
+// nextQuotaReset now 之后的下一个北京时间零点
+func nextQuotaReset(now time.Time) time.Time {
+	t := now.In(quotaResetLocation)
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, quotaResetLocation)
+}
+
+// exhausted 触发配额用尽回调
+func (l *RateLimiter) exhausted(api string, quota Quota) {
+	if l.onExhausted != nil {
+		l.onExhausted(api, quota)
+	}
+}
@@ -0,0 +1,101 @@
+package wechat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestRateLimiter(t *testing.T) {
+
+	now := time.Now()
+	var exhausted []string
+
+	l := NewRateLimiter(
+		WithQuota("/api", Quota{Limit: 2, Per: time.Hour}),
+		WithQuota("/cgi-bin/token", Quota{}),
+		WithQuotaExhaustedHook(func(api string, quota Quota) { exhausted = append(exhausted, api) }),
+	)
+	l.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := l.Wait(ctx, "appid", "/api"); err != nil {
+			t.Fatalf("wait %d: %v", i, err)
+		}
+	}
+	if err := l.Wait(ctx, "appid", "/api"); !errors.Is(err, ErrQuotaExhausted) {
+		t.Fatalf("err: %v", err)
+	}
+	if len(exhausted) != 1 || exhausted[0] != "/api" {
+		t.Fatalf("exhausted: %v", exhausted)
+	}
+
+	now = now.Add(30 * time.Minute)
+	if err := l.Wait(ctx, "appid", "/api"); err != nil {
+		t.Fatalf("refilled wait: %v", err)
+	}
+
+	now = now.Add(time.Hour)
+	l.Drain("appid", "/api", ErrFrequencyLimit)
+	if err := l.Wait(ctx, "appid", "/api"); !errors.Is(err, ErrQuotaExhausted) {
+		t.Fatalf("drained err: %v", err)
+	}
+
+	// 已为排队请求预留的令牌不被清零
+	l.buckets[bucketKey("appid", "/api")].tokens = -1.5
+	l.Drain("appid", "/api", ErrFrequencyLimit)
+	if tokens := l.buckets[bucketKey("appid", "/api")].tokens; tokens != -1.5 {
+		t.Fatalf("tokens: %v", tokens)
+	}
+
+	exhausted = nil
+	l.Drain("appid", "/unknown", ErrFrequencyLimit)
+	if len(exhausted) != 0 {
+		t.Fatalf("unconfigured api should not trigger hook: %v", exhausted)
+	}
+
+	for i := 0; i < 3000; i++ {
+		if err := l.Wait(ctx, "appid", "/cgi-bin/token"); err != nil {
+			t.Fatalf("unlimited api: %v", err)
+		}
+	}
+}
+
+func TestRateLimiterDailyLimit(t *testing.T) {
+
+	now := time.Date(2017, 3, 13, 10, 0, 0, 0, quotaResetLocation)
+
+	l := NewRateLimiter(WithQuota("/api", Quota{Limit: 100, Per: 24 * time.Hour}))
+	l.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	if err := l.Wait(ctx, "appid", "/api"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	l.Drain("appid", "/api", ErrDailyLimit)
+
+	// 日限额用尽后不按速率恢复，直至次日零点
+	now = now.Add(13*time.Hour + 59*time.Minute)
+	if err := l.Wait(ctx, "appid", "/api"); !errors.Is(err, ErrQuotaExhausted) {
+		t.Fatalf("err: %v", err)
+	}
+
+	// 配额按小程序分别计算
+	if err := l.Wait(ctx, "other", "/api"); err != nil {
+		t.Fatalf("other appid: %v", err)
+	}
+
+	now = time.Date(2017, 3, 14, 0, 0, 0, 0, quotaResetLocation)
+	for i := 0; i < 100; i++ {
+		if err := l.Wait(ctx, "appid", "/api"); err != nil {
+			t.Fatalf("after reset %d: %v", i, err)
+		}
+	}
+
+	if reset := nextQuotaReset(time.Date(2017, 3, 13, 16, 30, 0, 0, time.UTC)); !reset.Equal(time.Date(2017, 3, 15, 0, 0, 0, 0, quotaResetLocation)) {
+		t.Fatalf("reset: %v", reset)
+	}
+}
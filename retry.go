@@ -0,0 +1,76 @@
+package wechat
+
+import (
+	"context"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultRetryPolicy 默认重试策略
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// RetryPolicy 重试策略。errcode -1 系统繁忙、网络超时、连接重置与 5xx 仅对 GET 等幂等请求重试，
+// 因为 POST 请求可能已被微信处理；40029 等确定性错误从不重试
+type RetryPolicy struct {
+	MaxAttempts        int           // 最大尝试次数（含首次），小于等于 1 时不重试
+	InitialBackoff     time.Duration // 首次重试前的等待时间
+	MaxBackoff         time.Duration // 等待时间上限，为 0 时不限制
+	Multiplier         float64       // 每次重试等待时间的增长倍数，小于 1 时按 1 处理
+	Jitter             float64       // 随机抖动比例，取值 0~1，避免多个实例同时重试
+	RetryNonIdempotent bool          // 系统繁忙、网络错误与 5xx 时是否也重试 POST 等非幂等请求
+}
+
+// backoff 第 attempt 次失败后的等待时间
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+
+	multiplier := math.Max(p.Multiplier, 1)
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(rand.Float64()*2-1)
+	}
+	return time.Duration(d)
+}
+
+// shouldRetry 根据错误类型与请求是否幂等判断是否重试
+func (p RetryPolicy) shouldRetry(ctx context.Context, err error, idempotent bool) bool {
+
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if _, ok := AsError(err); ok {
+		// 系统繁忙时请求可能已被处理，如客服消息已下发，非幂等请求重试会造成重复
+		return IsRetryable(err) && (idempotent || p.RetryNonIdempotent)
+	}
+
+	transient := false
+	var statusErr *HTTPStatusError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &statusErr):
+		transient = statusErr.StatusCode >= 500
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET):
+		// 连接被对端关闭或重置
+		transient = true
+	case errors.As(err, &netErr):
+		// *url.Error 均实现 net.Error，协议不支持、证书错误、域名不存在、连接被拒绝等不会自行恢复，只重试超时
+		transient = netErr.Timeout()
+	}
+
+	return transient && (idempotent || p.RetryNonIdempotent)
+}
@@ -0,0 +1,140 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestRetryPolicy(t *testing.T) {
+
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 2}
+
+	tests := []struct {
+		name      string
+		responses []string // 依次返回，"503" 表示http状态码
+		post      bool
+		attempts  int
+		want      error
+	}{
+		{"get 5xx then permanent errcode", []string{"503", "503", `{"errcode":40029,"errmsg":"invalid code"}`}, false, 3, ErrInvalidCode},
+		{"permanent errcode", []string{`{"errcode":40029,"errmsg":"invalid code"}`}, false, 1, ErrInvalidCode},
+		{"post 5xx not retried", []string{"503"}, true, 1, nil},
+		{"post system busy not retried", []string{`{"errcode":-1,"errmsg":"system error"}`, `{"errcode":45011,"errmsg":"freq limit"}`}, true, 1, ErrSystemBusy},
+		{"budget exhausted", []string{`{"errcode":-1}`, `{"errcode":-1}`, `{"errcode":-1}`, `{"errcode":-1}`}, false, 3, ErrSystemBusy},
+	}
+
+	for _, tt := range tests {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			resp := tt.responses[attempts]
+			attempts++
+			if resp == "503" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, resp)
+		}))
+
+		client := NewClient(WithBaseURL(server.URL), WithCredential("appid", "secret"), WithRetryPolicy(policy))
+
+		var err error
+		if tt.post {
+			err = client.httpPostJSON(context.Background(), client.apiURL("/datacube/getweanalysisappiddailysummarytrend?access_token=token"),
+				&GetDatacubeRequest{BeginDate: "20170313", EndDate: "20170313"}, new(GetDailySummaryResponse))
+		} else {
+			err = client.Code2Session(&Code2SessionRequest{JsCode: "code"}, new(Code2SessionResponse))
+		}
+		server.Close()
+
+		if attempts != tt.attempts {
+			t.Errorf("%s: attempts %d, want %d", tt.name, attempts, tt.attempts)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: err %v, want %v", tt.name, err, tt.want)
+		}
+		if tt.want == nil && err == nil {
+			t.Errorf("%s: want error", tt.name)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond, Multiplier: 2}
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, w := range want {
+		if got := policy.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.backoff(1); got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Fatalf("jittered backoff %v out of range", got)
+		}
+	}
+}
+
+func TestRetryBackoffCanceled(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"errcode":-1,"errmsg":"system error"}`)
+	}))
+	defer server.Close()
+
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}
+	client := NewClient(WithBaseURL(server.URL), WithCredential("appid", "secret"), WithRetryPolicy(policy))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := client.Code2SessionContext(ctx, &Code2SessionRequest{JsCode: "code"}, new(Code2SessionResponse))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+// countingTransport 记录发出的请求数
+type countingTransport struct {
+	n         int
+	transport http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.n++
+	return t.transport.RoundTrip(req)
+}
+
+func TestRetryPermanentTransportError(t *testing.T) {
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"errcode":0}`)
+	}))
+	defer server.Close()
+
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	for _, baseURL := range []string{
+		"ftp://api.weixin.qq.com", // 不支持的协议
+		server.URL,                // 证书不受信任
+	} {
+		transport := &countingTransport{transport: http.DefaultTransport}
+		client := NewClient(WithBaseURL(baseURL), WithCredential("appid", "secret"), WithRetryPolicy(policy),
+			WithHTTPClient(&http.Client{Transport: transport}))
+
+		if err := client.Code2Session(&Code2SessionRequest{JsCode: "code"}, new(Code2SessionResponse)); err == nil {
+			t.Fatalf("%s: want error", baseURL)
+		}
+		if transport.n != 1 {
+			t.Errorf("%s: attempts %d, want 1", baseURL, transport.n)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package wechat
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+// DefaultSessionTTL 默认会话有效期
+const DefaultSessionTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrSessionNotFound 会话不存在或已过期，需重新登录
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionUserMismatch 轮换会话时新 code 对应的用户与原会话不一致
+	ErrSessionUserMismatch = errors.New("session user mismatch")
+)
+
+// SessionManager 会话管理器，登录后签发随机的会话 id 交给小程序，
+// openid、unionid 与 session_key 只保存在服务端的 SessionStore 中
+type SessionManager struct {
+	client *Client
+	store  SessionStore
+	ttl    time.Duration
+}
+
+// SessionOption 会话管理器配置项
+type SessionOption func(*SessionManager)
+
+// WithSessionStore 设置会话存储，默认为进程内存储
+func WithSessionStore(store SessionStore) SessionOption {
+	return func(m *SessionManager) {
+		m.store = store
+	}
+}
+
+// WithSessionTTL 设置会话有效期，默认 DefaultSessionTTL
+func WithSessionTTL(d time.Duration) SessionOption {
+	return func(m *SessionManager) {
+		if d > 0 {
+			m.ttl = d
+		}
+	}
+}
+
+// NewSessionManager 创建会话管理器，通过 client 调用 code2Session
+func NewSessionManager(client *Client, opts ...SessionOption) *SessionManager {
+	m := &SessionManager{
+		client: client,
+		ttl:    DefaultSessionTTL,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.store == nil {
+		m.store = NewMemorySessionStore()
+	}
+	return m
+}
+
+// key 存储中的键，同一存储可保存多个小程序的会话
+func (m *SessionManager) key(id string) string {
+	return "wechat:session:" + m.client.appID + ":" + id
+}
+
+// Login 使用 wx.login 获取的 code 登录，保存会话并返回新的会话 id
+func (m *SessionManager) Login(jsCode string) (string, *LoginSession, error) {
+	return m.LoginContext(context.Background(), jsCode)
+}
+
+// LoginContext 同 Login，ctx 用于取消请求或设置超时
+func (m *SessionManager) LoginContext(ctx context.Context, jsCode string) (string, *LoginSession, error) {
+
+	resp := new(Code2SessionResponse)
+	if err := m.client.Code2SessionContext(ctx, &Code2SessionRequest{JsCode: jsCode}, resp); err != nil {
+		return "", nil, err
+	}
+
+	id, err := m.save(resp)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, m.client.NewLoginSession(resp), nil
+}
+
+// Get 按会话 id 获取登录会话，不存在或已过期时返回 ErrSessionNotFound
+func (m *SessionManager) Get(id string) (*LoginSession, error) {
+
+	if err := validation.Validate(id, validation.Required); err != nil {
+		return nil, errors.Wrap(err, "request param error")
+	}
+
+	resp, err := m.store.Get(m.key(id))
+	if err != nil {
+		return nil, errors.Wrap(err, "session store error")
+	}
+	if resp == nil {
+		return nil, ErrSessionNotFound
+	}
+	return m.client.NewLoginSession(resp), nil
+}
+
+// Delete 删除会话，如用户退出登录
+func (m *SessionManager) Delete(id string) error {
+	if err := m.store.Delete(m.key(id)); err != nil {
+		return errors.Wrap(err, "session store error")
+	}
+	return nil
+}
+
+// Check 获取登录会话并校验会话密钥，会话密钥失效时返回 ErrInvalidSignature，此时应使用新的 code 调用 Rotate
+func (m *SessionManager) Check(id string) (*LoginSession, error) {
+	return m.CheckContext(context.Background(), id)
+}
+
+// CheckContext 同 Check，ctx 用于取消请求或设置超时
+func (m *SessionManager) CheckContext(ctx context.Context, id string) (*LoginSession, error) {
+
+	session, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err = session.CheckSessionKeyContext(ctx); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// ResetSessionKey 重置会话密钥并保存，会话 id 不变，有效期重新计算
+func (m *SessionManager) ResetSessionKey(id string) (*LoginSession, error) {
+	return m.ResetSessionKeyContext(context.Background(), id)
+}
+
+// ResetSessionKeyContext 同 ResetSessionKey，ctx 用于取消请求或设置超时
+func (m *SessionManager) ResetSessionKeyContext(ctx context.Context, id string) (*LoginSession, error) {
+
+	session, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err = session.ResetSessionKeyContext(ctx); err != nil {
+		return nil, err
+	}
+	if err = m.store.Set(m.key(id), session.response(), m.ttl); err != nil {
+		return nil, errors.Wrap(err, "session store error")
+	}
+	return session, nil
+}
+
+// Rotate 使用新的 code 重新登录并替换会话，用于 session_key 失效时。
+// 新 code 需属于原会话的用户，先删除原会话再保存新会话，删除失败时不签发新的会话 id，
+// 避免同一用户同时存在两个有效会话；成功后返回新的会话 id
+func (m *SessionManager) Rotate(id, jsCode string) (string, *LoginSession, error) {
+	return m.RotateContext(context.Background(), id, jsCode)
+}
+
+// RotateContext 同 Rotate，ctx 用于取消请求或设置超时
+func (m *SessionManager) RotateContext(ctx context.Context, id, jsCode string) (string, *LoginSession, error) {
+
+	old, err := m.Get(id)
+	if err != nil {
+		return "", nil, err
+	}
+
+	resp := new(Code2SessionResponse)
+	if err = m.client.Code2SessionContext(ctx, &Code2SessionRequest{JsCode: jsCode}, resp); err != nil {
+		return "", nil, err
+	}
+	if resp.OpenID != old.OpenID {
+		return "", nil, errors.Wrapf(ErrSessionUserMismatch, "openid %q", resp.OpenID)
+	}
+
+	if err = m.Delete(id); err != nil {
+		return "", nil, err
+	}
+	newID, err := m.save(resp)
+	if err != nil {
+		return "", nil, err
+	}
+	return newID, m.client.NewLoginSession(resp), nil
+}
+
+// save 以新的会话 id 保存会话
+func (m *SessionManager) save(resp *Code2SessionResponse) (string, error) {
+
+	id, err := newSessionID()
+	if err != nil {
+		return "", err
+	}
+	if err = m.store.Set(m.key(id), resp, m.ttl); err != nil {
+		return "", errors.Wrap(err, "session store error")
+	}
+	return id, nil
+}
+
+// newSessionID 生成 32 字节随机数的十六进制会话 id
+func newSessionID() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", errors.Wrap(err, "generate session id error")
+	}
+	return hex.EncodeToString(b[:]), nil
+}
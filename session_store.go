@@ -0,0 +1,81 @@
+package wechat
+
+import (
+	"sync"
+	"time"
+)
+
+// SessionStore 登录会话存储，保存 code2Session 的响应直至过期。
+// 多实例部署时需共享同一存储，可自行实现基于 Redis、数据库等的存储
+type SessionStore interface {
+	// Get 获取会话，不存在或已过期时返回 nil
+	Get(key string) (*Code2SessionResponse, error)
+	// Set 保存会话，ttl 后过期
+	Set(key string, session *Code2SessionResponse, ttl time.Duration) error
+	// Delete 删除会话，不存在时不返回错误
+	Delete(key string) error
+}
+
+// MemorySessionStore 进程内存储
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+}
+
+type memorySession struct {
+	session   Code2SessionResponse
+	expiresAt time.Time
+}
+
+// NewMemorySessionStore 创建进程内存储
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]memorySession)}
+}
+
+// Get 获取会话
+func (s *MemorySessionStore) Get(key string) (*Code2SessionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.sessions[key]
+	if !ok {
+		return nil, nil
+	}
+	if !time.Now().Before(m.expiresAt) {
+		delete(s.sessions, key)
+		return nil, nil
+	}
+	session := m.session
+	return &session, nil
+}
+
+// memorySweepSample 每次 Set 检查的会话数
+const memorySweepSample = 20
+
+// Set 保存会话，同时抽查少量会话并清理其中已过期的，其余过期会话在 Get 时清理
+func (s *MemorySessionStore) Set(key string, session *Code2SessionResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	n := 0
+	for k, m := range s.sessions { // map 遍历起点随机，相当于随机抽样
+		if n++; n > memorySweepSample {
+			break
+		}
+		if !now.Before(m.expiresAt) {
+			delete(s.sessions, k)
+		}
+	}
+	s.sessions[key] = memorySession{session: *session, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+// Delete 删除会话
+func (s *MemorySessionStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, key)
+	return nil
+}
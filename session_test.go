@@ -0,0 +1,163 @@
+package wechat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jayecc/wechat/wechattest"
+	"github.com/pkg/errors"
+)
+
+func TestSessionManager(t *testing.T) {
+
+	server := wechattest.NewServer()
+	defer server.Close()
+	server.AddCode("CODE1", wechattest.Session{OpenID: "OPENID", SessionKey: "KEY1", UnionID: "UNIONID"})
+	server.AddCode("CODE2", wechattest.Session{OpenID: "OPENID", SessionKey: "KEY2", UnionID: "UNIONID"})
+	server.AddCode("OTHER", wechattest.Session{OpenID: "OTHER", SessionKey: "KEY3"})
+
+	client := NewClient(WithBaseURL(server.URL), WithCredential(server.AppID, server.Secret))
+	store := NewMemorySessionStore()
+	manager := NewSessionManager(client, WithSessionStore(store))
+
+	id, session, err := manager.Login("CODE1")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(id) != 64 || session.OpenID != "OPENID" || session.UnionID != "UNIONID" {
+		t.Fatalf("id: %s, session: %v", id, session)
+	}
+	if stored, _ := store.Get("wechat:session:" + server.AppID + ":" + id); stored == nil || stored.SessionKey != "KEY1" {
+		t.Fatalf("stored: %+v", stored)
+	}
+
+	got, err := manager.Get(id)
+	if err != nil || got.OpenID != "OPENID" || got.sessionKey != "KEY1" {
+		t.Fatalf("get: %v %v", got, err)
+	}
+	if _, err = manager.Get("unknown"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, _, err = manager.Rotate(id, "OTHER"); !errors.Is(err, ErrSessionUserMismatch) {
+		t.Fatalf("err: %v", err)
+	}
+	newID, rotated, err := manager.Rotate(id, "CODE2")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if newID == id || rotated.sessionKey != "KEY2" {
+		t.Fatalf("rotated: %s %v", newID, rotated)
+	}
+	if _, err = manager.Get(id); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("old session should be gone: %v", err)
+	}
+
+	if _, err = manager.Check(newID); err != nil {
+		t.Fatalf("%v", err)
+	}
+	reset, err := manager.ResetSessionKey(newID)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if reset.sessionKey != server.SessionKey("OPENID") || reset.sessionKey == "KEY2" {
+		t.Fatalf("reset: %s", reset.sessionKey)
+	}
+	if got, _ = manager.Get(newID); got.sessionKey != reset.sessionKey {
+		t.Fatalf("reset key not saved: %s", got.sessionKey)
+	}
+
+	// 小程序端重新登录后服务端保存的 session_key 失效
+	server.AddCode("CODE3", wechattest.Session{OpenID: "OPENID", SessionKey: "KEY4"})
+	if _, err = manager.Check(newID); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("err: %v", err)
+	}
+	if newID, _, err = manager.Rotate(newID, "CODE3"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, err = manager.Check(newID); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if err = manager.Delete(newID); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, err = manager.Get(newID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+// failingDeleteStore 删除总是失败的会话存储
+type failingDeleteStore struct {
+	*MemorySessionStore
+}
+
+func (s failingDeleteStore) Delete(key string) error {
+	return errors.New("store unavailable")
+}
+
+func TestSessionRotateDeleteFailed(t *testing.T) {
+
+	server := wechattest.NewServer()
+	defer server.Close()
+	server.AddCode("CODE1", wechattest.Session{OpenID: "OPENID", SessionKey: "KEY1"})
+	server.AddCode("CODE2", wechattest.Session{OpenID: "OPENID", SessionKey: "KEY2"})
+
+	client := NewClient(WithBaseURL(server.URL), WithCredential(server.AppID, server.Secret))
+	store := failingDeleteStore{NewMemorySessionStore()}
+	manager := NewSessionManager(client, WithSessionStore(store))
+
+	id, _, err := manager.Login("CODE1")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, _, err = manager.Rotate(id, "CODE2"); err == nil {
+		t.Fatal("want delete error")
+	}
+
+	// 原会话无法删除时不签发新会话
+	if n := len(store.sessions); n != 1 {
+		t.Fatalf("sessions: %d, want 1", n)
+	}
+	if got, err := manager.Get(id); err != nil || got.sessionKey != "KEY1" {
+		t.Fatalf("get: %v %v", got, err)
+	}
+}
+
+func TestMemorySessionStore(t *testing.T) {
+
+	store := NewMemorySessionStore()
+	if err := store.Set("a", &Code2SessionResponse{OpenID: "A"}, time.Hour); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := store.Set("b", &Code2SessionResponse{OpenID: "B"}, -time.Second); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if s, _ := store.Get("a"); s == nil || s.OpenID != "A" {
+		t.Fatalf("a: %+v", s)
+	}
+	if s, _ := store.Get("b"); s != nil {
+		t.Fatalf("expired b: %+v", s)
+	}
+
+	s, _ := store.Get("a")
+	s.OpenID = "modified"
+	if s, _ = store.Get("a"); s.OpenID != "A" {
+		t.Fatal("store should return a copy")
+	}
+
+	store.Delete("a")
+	if s, _ = store.Get("a"); s != nil {
+		t.Fatalf("deleted a: %+v", s)
+	}
+
+	// 会话数不超过抽查数量时，Set 会清理全部过期会话
+	for _, key := range []string{"c", "d", "e"} {
+		store.Set(key, &Code2SessionResponse{}, -time.Second)
+	}
+	store.Set("f", &Code2SessionResponse{}, time.Hour)
+	if n := len(store.sessions); n != 1 {
+		t.Fatalf("sessions: %d", n)
+	}
+}
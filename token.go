@@ -0,0 +1,237 @@
+package wechat
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultTokenRefreshAhead 默认在 access_token 过期前多久主动刷新
+	DefaultTokenRefreshAhead = 5 * time.Minute
+	// tokenLockTTL 刷新锁有效期，也是等待其他进程刷新的最长时间
+	tokenLockTTL = 10 * time.Second
+	// tokenLockRetryInterval 刷新锁被占用时的重试间隔
+	tokenLockRetryInterval = 100 * time.Millisecond
+)
+
+// TokenManager access_token 管理器，凭证保存在 TokenStore 中直至临近过期，
+// 进程内并发刷新只请求一次，跨进程刷新通过存储的锁互斥
+type TokenManager struct {
+	client       *Client
+	store        TokenStore
+	refreshAhead time.Duration
+	now          func() time.Time
+	fetch        func(ctx context.Context) (string, time.Duration, error)
+
+	mu       sync.Mutex
+	stale    string
+	lifetime time.Duration // 最近一次请求到的凭证有效期
+	call     *tokenCall
+}
+
+// tokenCall 进行中的刷新
+type tokenCall struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// TokenOption 凭证管理器配置项
+type TokenOption func(*TokenManager)
+
+// WithTokenRefreshAhead 设置在过期前多久主动刷新，实际不超过凭证有效期的一半
+func WithTokenRefreshAhead(d time.Duration) TokenOption {
+	return func(m *TokenManager) {
+		m.refreshAhead = d
+	}
+}
+
+// WithTokenStore 设置凭证存储，默认为进程内存储
+func WithTokenStore(store TokenStore) TokenOption {
+	return func(m *TokenManager) {
+		m.store = store
+	}
+}
+
+// NewTokenManager 创建凭证管理器，通过 client 的凭证调用 GetAccessToken
+func NewTokenManager(client *Client, opts ...TokenOption) *TokenManager {
+	m := &TokenManager{
+		client:       client,
+		refreshAhead: DefaultTokenRefreshAhead,
+		now:          time.Now,
+	}
+	m.fetch = m.fetchAccessToken
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.store == nil {
+		m.store = NewMemoryTokenStore()
+	}
+	return m
+}
+
+// key 存储中的键，同一存储可保存多个小程序的凭证
+func (m *TokenManager) key() string {
+	return "wechat:access_token:" + m.client.AppID()
+}
+
+// Token 获取有效的 access_token，缓存即将过期时刷新
+func (m *TokenManager) Token() (string, error) {
+	return m.TokenContext(context.Background())
+}
+
+// TokenContext 同 Token，ctx 用于取消等待或设置超时
+func (m *TokenManager) TokenContext(ctx context.Context) (string, error) {
+
+	token, expiresAt, err := m.store.Get(m.key())
+	if err != nil {
+		return "", errors.Wrap(err, "token store error")
+	}
+	if m.usable(token, expiresAt) {
+		return token, nil
+	}
+
+	return m.refresh(ctx)
+}
+
+// Refresh 强制刷新 access_token，并发调用共享同一次请求
+func (m *TokenManager) Refresh() (string, error) {
+	return m.RefreshContext(context.Background())
+}
+
+// RefreshContext 同 Refresh，ctx 用于取消等待或设置超时
+func (m *TokenManager) RefreshContext(ctx context.Context) (string, error) {
+	m.Invalidate()
+	return m.refresh(ctx)
+}
+
+// Invalidate 将存储中当前的 access_token 标记为失效，下次获取时重新请求
+func (m *TokenManager) Invalidate() {
+	token, _, _ := m.store.Get(m.key())
+	m.invalidate(token)
+}
+
+// invalidate 将被微信拒绝的 access_token 标记为失效，存储中已是其他进程刷新的新凭证时不受影响
+func (m *TokenManager) invalidate(token string) {
+	m.mu.Lock()
+	m.stale = token
+	m.mu.Unlock()
+}
+
+// usable 凭证存在、未被标记失效且未临近过期
+func (m *TokenManager) usable(token string, expiresAt time.Time) bool {
+	m.mu.Lock()
+	stale, lifetime := m.stale, m.lifetime
+	m.mu.Unlock()
+
+	// 有效期不长于提前量时避免每次获取都刷新
+	ahead := m.refreshAhead
+	if lifetime > 0 && ahead > lifetime/2 {
+		ahead = lifetime / 2
+	}
+	return token != "" && token != stale && m.now().Add(ahead).Before(expiresAt)
+}
+
+// refresh 进程内合并并发刷新。刷新使用独立的 ctx，最长 tokenLockTTL，
+// 发起者取消不影响其他调用者；每个调用者只在自己的 ctx 内等待结果
+func (m *TokenManager) refresh(ctx context.Context) (string, error) {
+
+	m.mu.Lock()
+	call := m.call
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		m.call = call
+		go m.doRefresh(call)
+	}
+	m.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// doRefresh 执行一次刷新并通知所有等待者
+func (m *TokenManager) doRefresh(call *tokenCall) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), tokenLockTTL)
+	defer cancel()
+
+	call.token, call.err = m.load(ctx)
+
+	m.mu.Lock()
+	m.call = nil
+	m.mu.Unlock()
+	close(call.done)
+}
+
+// load 持有存储锁时请求新凭证；锁被其他进程占用时等待其刷新结果
+func (m *TokenManager) load(ctx context.Context) (string, error) {
+
+	deadline := m.now().Add(tokenLockTTL)
+	for {
+		unlock, err := m.store.Lock(m.key(), tokenLockTTL)
+		if err == nil {
+			defer unlock()
+			break
+		}
+		if err != ErrTokenLocked {
+			return "", errors.Wrap(err, "token store lock error")
+		}
+		if m.now().After(deadline) {
+			return "", errors.Wrap(err, "wait token refresh timeout")
+		}
+
+		select {
+		case <-time.After(tokenLockRetryInterval):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+
+		token, expiresAt, err := m.store.Get(m.key())
+		if err != nil {
+			return "", errors.Wrap(err, "token store error")
+		}
+		if m.usable(token, expiresAt) {
+			return token, nil
+		}
+	}
+
+	// 等锁期间其他进程可能已刷新
+	token, expiresAt, err := m.store.Get(m.key())
+	if err != nil {
+		return "", errors.Wrap(err, "token store error")
+	}
+	if m.usable(token, expiresAt) {
+		return token, nil
+	}
+
+	token, expiresIn, err := m.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	m.lifetime = expiresIn
+	m.mu.Unlock()
+	if err = m.store.Set(m.key(), token, expiresIn); err != nil {
+		return "", errors.Wrap(err, "token store error")
+	}
+
+	return token, nil
+}
+
+// fetchAccessToken 请求微信获取 access_token
+func (m *TokenManager) fetchAccessToken(ctx context.Context) (string, time.Duration, error) {
+
+	resp := new(GetAccessTokenResponse)
+	if err := m.client.GetAccessTokenContext(ctx, new(GetAccessTokenRequest), resp); err != nil {
+		return "", 0, errors.Wrap(err, "get access token error")
+	}
+
+	return resp.AccessToken, time.Duration(resp.ExpiresIn) * time.Second, nil
+}
@@ -0,0 +1,256 @@
+package wechat
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrTokenLocked 刷新锁已被其他持有者占用
+var ErrTokenLocked = errors.New("token store locked")
+
+// TokenStore access_token 存储。微信每次刷新都会使旧凭证失效，多进程部署时需共享同一存储，
+// 可自行实现基于 Redis、etcd 等的存储
+type TokenStore interface {
+	// Get 获取凭证，不存在或已过期时返回空字符串
+	Get(key string) (token string, expiresAt time.Time, err error)
+	// Set 保存凭证，ttl 后过期
+	Set(key string, token string, ttl time.Duration) error
+	// Lock 获取刷新锁，已被占用时返回 ErrTokenLocked；锁在 ttl 后自动释放，防止持有者崩溃导致死锁
+	Lock(key string, ttl time.Duration) (unlock func() error, err error)
+}
+
+// MemoryTokenStore 进程内存储
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]memoryToken
+	locks  map[string]time.Time
+}
+
+type memoryToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// NewMemoryTokenStore 创建进程内存储
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		tokens: make(map[string]memoryToken),
+		locks:  make(map[string]time.Time),
+	}
+}
+
+// Get 获取凭证
+func (s *MemoryTokenStore) Get(key string) (string, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[key]
+	if !ok || !time.Now().Before(t.expiresAt) {
+		return "", time.Time{}, nil
+	}
+	return t.token, t.expiresAt, nil
+}
+
+// Set 保存凭证
+func (s *MemoryTokenStore) Set(key string, token string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[key] = memoryToken{token: token, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// Lock 获取刷新锁
+func (s *MemoryTokenStore) Lock(key string, ttl time.Duration) (func() error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if expiresAt, ok := s.locks[key]; ok && time.Now().Before(expiresAt) {
+		return nil, ErrTokenLocked
+	}
+	expiresAt := time.Now().Add(ttl)
+	s.locks[key] = expiresAt
+
+	return func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.locks[key] == expiresAt {
+			delete(s.locks, key)
+		}
+		return nil
+	}, nil
+}
+
+// FileTokenStore 文件存储，以独占创建的锁文件实现同机多进程互斥
+type FileTokenStore struct {
+	dir string
+
+	expiredHook func() // 测试用，判断锁已过期后、接管前调用
+}
+
+// fileToken 凭证文件内容
+type fileToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewFileTokenStore 创建文件存储，凭证与锁文件保存在 dir 目录下
+func NewFileTokenStore(dir string) (*FileTokenStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "create token dir error")
+	}
+	return &FileTokenStore{dir: dir}, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// path 根据 key 生成文件路径
+func (s *FileTokenStore) path(key, ext string) string {
+	return filepath.Join(s.dir, unsafeFileChars.ReplaceAllString(key, "_")+ext)
+}
+
+// Get 获取凭证
+func (s *FileTokenStore) Get(key string) (string, time.Time, error) {
+
+	data, err := ioutil.ReadFile(s.path(key, ".json"))
+	if os.IsNotExist(err) {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	var t fileToken
+	if err = json.Unmarshal(data, &t); err != nil {
+		return "", time.Time{}, errors.Wrap(err, "decode token file error")
+	}
+	if !time.Now().Before(t.ExpiresAt) {
+		return "", time.Time{}, nil
+	}
+	return t.Token, t.ExpiresAt, nil
+}
+
+// Set 保存凭证，先写临时文件再重命名，避免其他进程读到写了一半的内容
+func (s *FileTokenStore) Set(key string, token string, ttl time.Duration) error {
+
+	data, err := json.Marshal(&fileToken{Token: token, ExpiresAt: time.Now().Add(ttl)})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(s.dir, ".token-")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(key, ".json"))
+}
+
+// Lock 获取刷新锁，锁文件记录过期时间与持有者标识，过期的锁会被清理
+func (s *FileTokenStore) Lock(key string, ttl time.Duration) (func() error, error) {
+
+	lockPath := s.path(key, ".lock")
+
+	var owner [8]byte
+	if _, err := rand.Read(owner[:]); err != nil {
+		return nil, err
+	}
+	content := strconv.FormatInt(time.Now().Add(ttl).UnixNano(), 10) + " " + hex.EncodeToString(owner[:])
+
+	for i := 0; i < 2; i++ {
+		f, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			_, err = f.WriteString(content)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(lockPath)
+				return nil, err
+			}
+			return func() error {
+				if data, err := ioutil.ReadFile(lockPath); err != nil || string(data) != content {
+					return nil
+				}
+				return os.Remove(lockPath)
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if err = s.takeOver(lockPath, ttl, hex.EncodeToString(owner[:])); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, ErrTokenLocked
+}
+
+// takeOver 清理过期的锁。先将锁文件重命名为本持有者独有的文件名，只有重命名成功的一方负责清理，
+// 并核对重命名后的内容与判断过期时一致；若其间锁已被其他进程重建，则将其放回并返回 ErrTokenLocked
+func (s *FileTokenStore) takeOver(lockPath string, ttl time.Duration, owner string) error {
+
+	data, expired := s.lockExpired(lockPath, ttl)
+	if !expired {
+		return ErrTokenLocked
+	}
+	if s.expiredHook != nil {
+		s.expiredHook()
+	}
+
+	stale := lockPath + ".stale-" + owner
+	if err := os.Rename(lockPath, stale); err != nil {
+		if os.IsNotExist(err) {
+			return nil // 已被其他进程清理，重新竞争
+		}
+		return err
+	}
+
+	if current, err := ioutil.ReadFile(stale); err == nil && !bytes.Equal(current, data) {
+		os.Link(stale, lockPath)
+		os.Remove(stale)
+		return ErrTokenLocked
+	}
+	return os.Remove(stale)
+}
+
+// lockExpired 锁文件是否已过期，同时返回判断时的内容；内容尚未写入时按文件修改时间判断
+func (s *FileTokenStore) lockExpired(lockPath string, ttl time.Duration) ([]byte, bool) {
+
+	info, err := os.Stat(lockPath)
+	if err != nil {
+		return nil, os.IsNotExist(err)
+	}
+
+	data, err := ioutil.ReadFile(lockPath)
+	if err != nil {
+		return nil, os.IsNotExist(err)
+	}
+
+	if fields := strings.Fields(string(data)); len(fields) > 0 {
+		if expiresAt, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			return data, time.Now().UnixNano() > expiresAt
+		}
+	}
+	return data, time.Since(info.ModTime()) > ttl
+}
@@ -0,0 +1,142 @@
+package wechat
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func testTokenStore(t *testing.T, store TokenStore) {
+
+	if token, _, err := store.Get("k"); err != nil || token != "" {
+		t.Fatalf("empty store token: %q, err: %v", token, err)
+	}
+
+	if err := store.Set("k", "v", time.Hour); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if token, expiresAt, err := store.Get("k"); err != nil || token != "v" || expiresAt.Before(time.Now()) {
+		t.Fatalf("token: %q, expiresAt: %v, err: %v", token, expiresAt, err)
+	}
+
+	if err := store.Set("k", "v", -time.Second); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if token, _, err := store.Get("k"); err != nil || token != "" {
+		t.Fatalf("expired token: %q, err: %v", token, err)
+	}
+
+	unlock, err := store.Lock("k", time.Hour)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, err = store.Lock("k", time.Hour); err != ErrTokenLocked {
+		t.Fatalf("second lock err: %v", err)
+	}
+	if err = unlock(); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if _, err = store.Lock("k", -time.Second); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, err = store.Lock("k", time.Hour); err != nil {
+		t.Fatalf("expired lock not released: %v", err)
+	}
+}
+
+func TestMemoryTokenStore(t *testing.T) {
+	testTokenStore(t, NewMemoryTokenStore())
+}
+
+func TestFileTokenStore(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "wechat-token")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileTokenStore(dir)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	testTokenStore(t, store)
+}
+
+func TestFileTokenStoreTakeOver(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "wechat-token")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileTokenStore(dir)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	lockPath := store.path("appid", ".lock")
+	stale := strconv.FormatInt(time.Now().Add(-time.Minute).UnixNano(), 10) + " stale"
+
+	// 多个持有者同时接管同一个过期的锁，只有一个成功
+	for round := 0; round < 50; round++ {
+		if err = ioutil.WriteFile(lockPath, []byte(stale), 0600); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		var (
+			wg      sync.WaitGroup
+			start   = make(chan struct{})
+			holders int32
+		)
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				if _, err := store.Lock("appid", time.Minute); err == nil {
+					atomic.AddInt32(&holders, 1)
+				} else if !errors.Is(err, ErrTokenLocked) {
+					t.Errorf("lock: %v", err)
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+
+		if holders != 1 {
+			t.Fatalf("round %d: %d holders", round, holders)
+		}
+	}
+
+	// 判断过期后、接管前锁已被其他进程接管并重建，不能删除其他进程的锁
+	if err = ioutil.WriteFile(lockPath, []byte(stale), 0600); err != nil {
+		t.Fatalf("%v", err)
+	}
+	other, _ := NewFileTokenStore(dir)
+	store.expiredHook = func() {
+		if _, err := other.Lock("appid", time.Minute); err != nil {
+			t.Fatalf("other: %v", err)
+		}
+	}
+	if _, err = store.Lock("appid", time.Minute); !errors.Is(err, ErrTokenLocked) {
+		t.Fatalf("err: %v", err)
+	}
+	store.expiredHook = nil
+	if _, err = other.Lock("appid", time.Minute); !errors.Is(err, ErrTokenLocked) {
+		t.Fatalf("other's lock should be kept: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.stale-*"))
+	if len(files) != 0 {
+		t.Fatalf("stale files left: %v", files)
+	}
+}
@@ -0,0 +1,128 @@
+package wechat
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenManager(t *testing.T) {
+
+	var fetches int32
+
+	m := NewTokenManager(NewClient())
+	m.fetch = func(ctx context.Context) (string, time.Duration, error) {
+		atomic.AddInt32(&fetches, 1)
+		time.Sleep(10 * time.Millisecond)
+		return "token", 2 * time.Hour, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token, err := m.Token(); err != nil || token != "token" {
+				t.Errorf("token: %q, err: %v", token, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := m.Token(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Fatalf("fetches: %d, want 1", n)
+	}
+
+	m.now = func() time.Time { return time.Now().Add(2*time.Hour - time.Minute) }
+	if _, err := m.Token(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Fatalf("fetches: %d, want 2", n)
+	}
+}
+
+func TestTokenManagerRefreshCanceled(t *testing.T) {
+
+	release := make(chan struct{})
+	m := NewTokenManager(NewClient())
+	m.fetch = func(ctx context.Context) (string, time.Duration, error) {
+		<-release
+		if err := ctx.Err(); err != nil {
+			return "", 0, err
+		}
+		if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > tokenLockTTL {
+			t.Errorf("deadline: %v %v", deadline, ok)
+		}
+		return "token", 2 * time.Hour, nil
+	}
+
+	// 发起刷新的调用者取消后，其他等待者仍拿到刷新结果
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error)
+	go func() {
+		_, err := m.TokenContext(ctx)
+		canceled <- err
+	}()
+	for {
+		m.mu.Lock()
+		started := m.call != nil
+		m.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	waiter := make(chan string)
+	go func() {
+		token, err := m.Token()
+		if err != nil {
+			t.Errorf("waiter: %v", err)
+		}
+		waiter <- token
+	}()
+
+	cancel()
+	if err := <-canceled; err != context.Canceled {
+		t.Fatalf("initiator err: %v", err)
+	}
+	close(release)
+	if token := <-waiter; token != "token" {
+		t.Fatalf("waiter token: %q", token)
+	}
+}
+
+func TestTokenManagerShortLifetime(t *testing.T) {
+
+	var fetches int32
+
+	m := NewTokenManager(NewClient(), WithTokenRefreshAhead(10*time.Minute))
+	m.fetch = func(ctx context.Context) (string, time.Duration, error) {
+		atomic.AddInt32(&fetches, 1)
+		return "token", 5 * time.Minute, nil
+	}
+
+	// 有效期短于提前量时按有效期的一半提前刷新，而不是每次都刷新
+	for i := 0; i < 3; i++ {
+		if _, err := m.Token(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Fatalf("fetches: %d, want 1", n)
+	}
+
+	m.now = func() time.Time { return time.Now().Add(3 * time.Minute) }
+	if _, err := m.Token(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Fatalf("fetches: %d, want 2", n)
+	}
+}
@@ -0,0 +1,250 @@
+package wechat
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+// DefaultWatermarkMaxAge 默认开放数据水印时间戳的最大时差
+const DefaultWatermarkMaxAge = 10 * time.Minute
+
+var (
+	// ErrDecrypt 解密失败，通常为 session_key 已更新或 encryptedData、iv 不匹配
+	ErrDecrypt = errors.New("decrypt user data error")
+	// ErrWatermarkAppID 水印 appid 与客户端 appId 不一致，数据不属于本小程序
+	ErrWatermarkAppID = errors.New("watermark appid mismatch")
+	// ErrWatermarkExpired 水印时间戳与当前时间相差超过允许范围，可能为重放的数据
+	ErrWatermarkExpired = errors.New("watermark timestamp expired")
+	// ErrSignatureMismatch rawData 签名校验失败，数据可能被篡改或 session_key 已更新
+	ErrSignatureMismatch = errors.New("signature mismatch")
+)
+
+// Watermark 开放数据水印
+type Watermark struct {
+	AppID     string `json:"appid"`     //敏感数据归属 appId，开发者可校验此参数与自身 appId 是否一致
+	Timestamp int64  `json:"timestamp"` //敏感数据获取的时间戳, 开发者可以用于数据时效性校验
+}
+
+// UserInfo 用户信息，wx.getUserInfo 的 encryptedData
+type UserInfo struct {
+	OpenID    string    `json:"openId"`    //用户唯一标识
+	NickName  string    `json:"nickName"`  //用户昵称
+	Gender    int       `json:"gender"`    //性别，0 未知，1 男性，2 女性
+	City      string    `json:"city"`      //用户所在城市
+	Province  string    `json:"province"`  //用户所在省份
+	Country   string    `json:"country"`   //用户所在国家
+	AvatarURL string    `json:"avatarUrl"` //用户头像图片的 URL
+	UnionID   string    `json:"unionId"`   //用户在开放平台的唯一标识符，满足 UnionID 下发条件时返回
+	Language  string    `json:"language"`  //显示 country，province，city 所用的语言
+	Watermark Watermark `json:"watermark"` //数据水印
+}
+
+// PhoneInfo 用户手机号，getPhoneNumber 的 encryptedData
+type PhoneInfo struct {
+	PhoneNumber     string    `json:"phoneNumber"`     //用户绑定的手机号（国外手机号会有区号）
+	PurePhoneNumber string    `json:"purePhoneNumber"` //没有区号的手机号
+	CountryCode     string    `json:"countryCode"`     //区号
+	Watermark       Watermark `json:"watermark"`       //数据水印
+}
+
+// ShareInfo 转发信息，wx.getShareInfo 的 encryptedData
+type ShareInfo struct {
+	OpenGID   string    `json:"openGId"`   //群对当前小程序的唯一 ID
+	Watermark Watermark `json:"watermark"` //数据水印
+}
+
+// RunData 微信运动步数，wx.getWeRunData 的 encryptedData
+type RunData struct {
+	StepInfoList []StepInfo `json:"stepInfoList"` //用户过去三十天的微信运动步数
+	Watermark    Watermark  `json:"watermark"`    //数据水印
+}
+
+// StepInfo 一天的微信运动步数
+type StepInfo struct {
+	Timestamp int64 `json:"timestamp"` //时间戳，表示数据对应的时间
+	Step      int   `json:"step"`      //微信运动步数
+}
+
+// VerifyUserInfoSignature 校验 wx.getUserInfo 返回的 rawData 签名，signature = sha1(rawData + session_key)
+// https://developers.weixin.qq.com/miniprogram/dev/framework/open-ability/signature.html
+func VerifyUserInfoSignature(rawData, signature, sessionKey string) bool {
+	sum := sha1.Sum([]byte(rawData + sessionKey))
+	return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(signature))) == 1
+}
+
+// DecryptUserData 解密开放数据 encryptedData 并校验水印，结果解析到 v。
+// 水印 appid 需与 appID 一致，时间戳与当前时间相差不超过 DefaultClient 的 WithWatermarkMaxAge 设置
+// https://developers.weixin.qq.com/miniprogram/dev/framework/open-ability/signature.html
+func DecryptUserData(appID, sessionKey, encryptedData, iv string, v interface{}) error {
+	return decodeUserData(appID, DefaultClient.watermarkMaxAge, sessionKey, encryptedData, iv, v)
+}
+
+// DecryptUserInfo 解密用户信息，appID 为小程序 appId
+func DecryptUserInfo(appID, sessionKey, encryptedData, iv string) (*UserInfo, error) {
+	info := new(UserInfo)
+	if err := DecryptUserData(appID, sessionKey, encryptedData, iv, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// DecryptPhoneNumber 解密用户手机号，appID 为小程序 appId
+func DecryptPhoneNumber(appID, sessionKey, encryptedData, iv string) (*PhoneInfo, error) {
+	info := new(PhoneInfo)
+	if err := DecryptUserData(appID, sessionKey, encryptedData, iv, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// DecryptShareInfo 解密转发信息，appID 为小程序 appId
+func DecryptShareInfo(appID, sessionKey, encryptedData, iv string) (*ShareInfo, error) {
+	info := new(ShareInfo)
+	if err := DecryptUserData(appID, sessionKey, encryptedData, iv, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// DecryptRunData 解密微信运动步数，appID 为小程序 appId
+func DecryptRunData(appID, sessionKey, encryptedData, iv string) (*RunData, error) {
+	data := new(RunData)
+	if err := DecryptUserData(appID, sessionKey, encryptedData, iv, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// DecryptUserData 解密开放数据 encryptedData 并校验水印，结果解析到 v。
+// 水印 appid 需与客户端 appId 一致，客户端未设置 appId 时返回错误；时间戳与当前时间相差不超过 WithWatermarkMaxAge 的设置
+func (c *Client) DecryptUserData(sessionKey, encryptedData, iv string, v interface{}) error {
+	return decodeUserData(c.appID, c.watermarkMaxAge, sessionKey, encryptedData, iv, v)
+}
+
+// DecryptUserInfo 解密用户信息
+func (c *Client) DecryptUserInfo(sessionKey, encryptedData, iv string) (*UserInfo, error) {
+	info := new(UserInfo)
+	if err := c.DecryptUserData(sessionKey, encryptedData, iv, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// DecryptPhoneNumber 解密用户手机号
+func (c *Client) DecryptPhoneNumber(sessionKey, encryptedData, iv string) (*PhoneInfo, error) {
+	info := new(PhoneInfo)
+	if err := c.DecryptUserData(sessionKey, encryptedData, iv, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// DecryptShareInfo 解密转发信息
+func (c *Client) DecryptShareInfo(sessionKey, encryptedData, iv string) (*ShareInfo, error) {
+	info := new(ShareInfo)
+	if err := c.DecryptUserData(sessionKey, encryptedData, iv, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// DecryptRunData 解密微信运动步数
+func (c *Client) DecryptRunData(sessionKey, encryptedData, iv string) (*RunData, error) {
+	data := new(RunData)
+	if err := c.DecryptUserData(sessionKey, encryptedData, iv, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// decodeUserData 解密开放数据并校验水印，appID 必填，maxAge 不大于 0 时不校验时间戳
+func decodeUserData(appID string, maxAge time.Duration, sessionKey, encryptedData, iv string, v interface{}) error {
+
+	if err := validation.Validate(appID, validation.Required); err != nil {
+		return errors.Wrap(err, "request param error: appid")
+	}
+
+	data, err := decryptUserData(sessionKey, encryptedData, iv)
+	if err != nil {
+		return err
+	}
+
+	var payload struct {
+		Watermark Watermark `json:"watermark"`
+	}
+	if err = json.Unmarshal(data, &payload); err != nil {
+		return errors.Wrap(ErrDecrypt, err.Error())
+	}
+	if err = checkWatermark(payload.Watermark, appID, maxAge); err != nil {
+		return err
+	}
+
+	if err = json.Unmarshal(data, v); err != nil {
+		return errors.Wrap(err, "user data decode error")
+	}
+	return nil
+}
+
+// checkWatermark 校验水印的 appid 与时间戳
+func checkWatermark(watermark Watermark, appID string, maxAge time.Duration) error {
+
+	if watermark.AppID != appID {
+		return errors.Wrapf(ErrWatermarkAppID, "appid %q", watermark.AppID)
+	}
+
+	if maxAge > 0 {
+		age := time.Since(time.Unix(watermark.Timestamp, 0))
+		if age > maxAge || age < -maxAge {
+			return errors.Wrapf(ErrWatermarkExpired, "timestamp %d", watermark.Timestamp)
+		}
+	}
+	return nil
+}
+
+// decryptUserData AES-128-CBC 解密，密钥为 session_key，数据采用 PKCS#7 填充，参数均为 base64 编码
+func decryptUserData(sessionKey, encryptedData, iv string) ([]byte, error) {
+
+	if err := validation.Validate(sessionKey, validation.Required); err != nil {
+		return nil, errors.Wrap(err, "request param error: session_key")
+	}
+	if err := validation.Validate(encryptedData, validation.Required); err != nil {
+		return nil, errors.Wrap(err, "request param error: encrypted_data")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(sessionKey)
+	if err != nil || len(key) != 16 {
+		return nil, errors.Wrap(ErrDecrypt, "session_key must be 16 bytes base64")
+	}
+	vector, err := base64.StdEncoding.DecodeString(iv)
+	if err != nil || len(vector) != aes.BlockSize {
+		return nil, errors.Wrap(ErrDecrypt, "iv must be 16 bytes base64")
+	}
+	data, err := base64.StdEncoding.DecodeString(encryptedData)
+	if err != nil || len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.Wrap(ErrDecrypt, "encrypted_data must be base64 of whole blocks")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(ErrDecrypt, err.Error())
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, vector).CryptBlocks(plain, data)
+
+	n := int(plain[len(plain)-1])
+	if n == 0 || n > aes.BlockSize || !bytes.Equal(plain[len(plain)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, errors.Wrap(ErrDecrypt, "invalid padding")
+	}
+	return plain[:len(plain)-n], nil
+}
@@ -0,0 +1,102 @@
+package wechat
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// encryptUserData 按微信开放数据的方式加密，返回 encryptedData
+func encryptUserData(t *testing.T, sessionKey, iv, plain string) string {
+
+	key, _ := base64.StdEncoding.DecodeString(sessionKey)
+	vector, _ := base64.StdEncoding.DecodeString(iv)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	n := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append([]byte(plain), bytes.Repeat([]byte{byte(n)}, n)...)
+	cipher.NewCBCEncrypter(block, vector).CryptBlocks(data, data)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestDecryptUserData(t *testing.T) {
+
+	sessionKey := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	iv := base64.StdEncoding.EncodeToString([]byte("fedcba9876543210"))
+	watermark := func(appID string, at time.Time) string {
+		return fmt.Sprintf(`"watermark":{"appid":%q,"timestamp":%d}`, appID, at.Unix())
+	}
+	now := time.Now()
+
+	client := NewClient(WithCredential("wx4f4bc4dec97d474b", "secret"))
+
+	data := encryptUserData(t, sessionKey, iv, `{"openId":"OPENID","nickName":"Band","gender":1,"city":"Guangzhou","unionId":"UNIONID",`+watermark("wx4f4bc4dec97d474b", now)+`}`)
+	info, err := client.DecryptUserInfo(sessionKey, data, iv)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if info.OpenID != "OPENID" || info.NickName != "Band" || info.Gender != 1 || info.UnionID != "UNIONID" || info.Watermark.Timestamp != now.Unix() {
+		t.Fatalf("user info: %+v", info)
+	}
+
+	data = encryptUserData(t, sessionKey, iv, `{"phoneNumber":"+8613800000000","purePhoneNumber":"13800000000","countryCode":"86",`+watermark("wx4f4bc4dec97d474b", now)+`}`)
+	if phone, err := client.DecryptPhoneNumber(sessionKey, data, iv); err != nil || phone.PurePhoneNumber != "13800000000" || phone.CountryCode != "86" {
+		t.Fatalf("phone: %+v %v", phone, err)
+	}
+
+	data = encryptUserData(t, sessionKey, iv, `{"stepInfoList":[{"timestamp":1445866601,"step":100},{"timestamp":1445876601,"step":120}],`+watermark("wx4f4bc4dec97d474b", now)+`}`)
+	if run, err := client.DecryptRunData(sessionKey, data, iv); err != nil || len(run.StepInfoList) != 2 || run.StepInfoList[1].Step != 120 {
+		t.Fatalf("run data: %+v %v", run, err)
+	}
+
+	data = encryptUserData(t, sessionKey, iv, `{"openGId":"OPENGID",`+watermark("wx0000000000000000", now)+`}`)
+	if _, err = client.DecryptShareInfo(sessionKey, data, iv); !errors.Is(err, ErrWatermarkAppID) {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err = DecryptShareInfo("wx4f4bc4dec97d474b", sessionKey, data, iv); !errors.Is(err, ErrWatermarkAppID) {
+		t.Fatalf("err: %v", err)
+	}
+	if share, err := DecryptShareInfo("wx0000000000000000", sessionKey, data, iv); err != nil || share.OpenGID != "OPENGID" {
+		t.Fatalf("share: %+v %v", share, err)
+	}
+	if _, err = NewClient().DecryptShareInfo(sessionKey, data, iv); err == nil || errors.Is(err, ErrWatermarkAppID) {
+		t.Fatalf("client without appid should fail: %v", err)
+	}
+	if _, err = DecryptShareInfo("", sessionKey, data, iv); err == nil {
+		t.Fatal("empty appid should fail")
+	}
+
+	data = encryptUserData(t, sessionKey, iv, `{"openGId":"OPENGID",`+watermark("wx4f4bc4dec97d474b", now.Add(-time.Hour))+`}`)
+	if _, err = client.DecryptShareInfo(sessionKey, data, iv); !errors.Is(err, ErrWatermarkExpired) {
+		t.Fatalf("err: %v", err)
+	}
+	client = NewClient(WithCredential("wx4f4bc4dec97d474b", "secret"), WithWatermarkMaxAge(0))
+	if _, err = client.DecryptShareInfo(sessionKey, data, iv); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	otherKey := base64.StdEncoding.EncodeToString([]byte("abcdef0123456789"))
+	for _, tc := range []struct{ sessionKey, data, iv string }{
+		{otherKey, data, iv},
+		{sessionKey, data, "short"},
+		{sessionKey, "not base64", iv},
+		{sessionKey, base64.StdEncoding.EncodeToString([]byte("odd length")), iv},
+		{"", data, iv},
+	} {
+		if _, err = client.DecryptShareInfo(tc.sessionKey, tc.data, tc.iv); err == nil {
+			t.Fatalf("%+v should fail", tc)
+		}
+	}
+	if _, err = client.DecryptShareInfo(otherKey, data, iv); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("err: %v", err)
+	}
+}
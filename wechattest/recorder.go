@@ -0,0 +1,299 @@
+package wechattest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Redacted 录制文件中凭证的替代值
+const Redacted = "REDACTED"
+
+// redactedFields 录制时脱敏的 query 参数与 JSON 字段
+var redactedFields = map[string]bool{"access_token": true, "secret": true, "session_key": true}
+
+// Mode 录制或回放
+type Mode int
+
+const (
+	// ModeReplay 从录制文件回放，不发出请求，没有匹配的录制时返回错误
+	ModeReplay Mode = iota
+	// ModeRecord 发出真实请求并覆盖录制文件
+	ModeRecord
+)
+
+// ModeFromEnv 环境变量 WECHAT_RECORD 非空时为 ModeRecord，否则为 ModeReplay
+func ModeFromEnv() Mode {
+	if os.Getenv("WECHAT_RECORD") != "" {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// Interaction 一次录制的请求与响应
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest 脱敏后的请求，回放时按全部字段匹配
+type RecordedRequest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query"`
+	Body   string `json:"body"`
+}
+
+// RecordedResponse 脱敏后的响应
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+	Base64 bool        `json:"base64,omitempty"` //Body 为非 UTF-8 数据的 base64 编码，如临时素材
+}
+
+// Recorder 录制与回放请求的 http.RoundTripper，可通过 Client 接入 wechat.WithHTTPClient。
+// 每个接口一个录制文件，如 /sns/jscode2session 保存为 dir/sns_jscode2session.json
+type Recorder struct {
+	dir       string
+	mode      Mode
+	transport http.RoundTripper
+
+	mu       sync.Mutex
+	recorded map[string][]Interaction // 录制模式下本次录制的内容
+	loaded   map[string][]Interaction // 回放模式下已读取的录制文件
+	used     map[string][]bool
+}
+
+// NewRecorder 创建录制器，transport 为录制时实际发出请求的 RoundTripper，为 nil 时使用 http.DefaultTransport
+func NewRecorder(dir string, mode Mode, transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{
+		dir:       dir,
+		mode:      mode,
+		transport: transport,
+		recorded:  make(map[string][]Interaction),
+		loaded:    make(map[string][]Interaction),
+		used:      make(map[string][]bool),
+	}
+}
+
+// Client 使用录制器的 http 客户端
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip 录制模式下发出请求并保存，回放模式下返回匹配的录制；不修改调用方的 req
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	recorded := redactRequest(req, body)
+
+	if r.mode == ModeRecord {
+		return r.record(req, recorded)
+	}
+	return r.replay(req, recorded)
+}
+
+// record 发出请求并追加到录制文件
+func (r *Recorder) record(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	response := RecordedResponse{Status: resp.StatusCode, Header: http.Header{}}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		response.Header.Set("Content-Type", contentType)
+	}
+	if utf8.Valid(data) {
+		response.Body = redactBody(data)
+	} else {
+		response.Body = base64.StdEncoding.EncodeToString(data)
+		response.Base64 = true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := fixtureName(recorded.Path)
+	r.recorded[name] = append(r.recorded[name], Interaction{Request: recorded, Response: response})
+	if err = r.save(name); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replay 返回第一个未使用的匹配录制，全部使用过时重复最后一个
+func (r *Recorder) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := fixtureName(recorded.Path)
+	interactions, err := r.load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	match := -1
+	for i, interaction := range interactions {
+		if interaction.Request != recorded {
+			continue
+		}
+		match = i
+		if !r.used[name][i] {
+			break
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("wechattest: no recorded response in %s for %s %s?%s %s",
+			name, recorded.Method, recorded.Path, recorded.Query, recorded.Body)
+	}
+	r.used[name][match] = true
+
+	response := interactions[match].Response
+	body := []byte(response.Body)
+	if response.Base64 {
+		if body, err = base64.StdEncoding.DecodeString(response.Body); err != nil {
+			return nil, err
+		}
+	}
+	header := http.Header{}
+	for k, v := range response.Header {
+		header[k] = v
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.Status, http.StatusText(response.Status)),
+		StatusCode:    response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// load 读取录制文件，调用方需持有锁
+func (r *Recorder) load(name string) ([]Interaction, error) {
+
+	if interactions, ok := r.loaded[name]; ok {
+		return interactions, nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(r.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("wechattest: read fixture: %v", err)
+	}
+	var interactions []Interaction
+	if err = json.Unmarshal(data, &interactions); err != nil {
+		return nil, fmt.Errorf("wechattest: parse fixture %s: %v", name, err)
+	}
+	r.loaded[name] = interactions
+	r.used[name] = make([]bool, len(interactions))
+	return interactions, nil
+}
+
+// save 写入录制文件，调用方需持有锁
+func (r *Recorder) save(name string) error {
+	data, err := json.MarshalIndent(r.recorded[name], "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(r.dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(r.dir, name), append(data, '\n'), 0644)
+}
+
+// fixtureName 接口对应的录制文件名
+func fixtureName(path string) string {
+	return strings.Replace(strings.Trim(path, "/"), "/", "_", -1) + ".json"
+}
+
+// redactRequest 脱敏请求，query 按参数名排序
+func redactRequest(req *http.Request, body []byte) RecordedRequest {
+
+	query := req.URL.Query()
+	for name := range query {
+		if redactedFields[name] && query.Get(name) != "" {
+			query.Set(name, Redacted)
+		}
+	}
+
+	recorded := RecordedRequest{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  query.Encode(),
+	}
+	// multipart 的 boundary 每次不同，不参与匹配
+	if !strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/") {
+		recorded.Body = redactBody(body)
+	}
+	return recorded
+}
+
+// redactBody 脱敏 JSON 并统一为按字段名排序的紧凑格式，其他内容原样返回
+func redactBody(body []byte) string {
+
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err == nil && !decoder.More() {
+		if data, err := json.Marshal(redactJSON(v)); err == nil {
+			return string(data)
+		}
+	}
+	return string(body)
+}
+
+// redactJSON 替换 JSON 中的凭证字段
+func redactJSON(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, field := range value {
+			if s, ok := field.(string); ok && redactedFields[k] && s != "" {
+				value[k] = Redacted
+				continue
+			}
+			value[k] = redactJSON(field)
+		}
+	case []interface{}:
+		for i := range value {
+			value[i] = redactJSON(value[i])
+		}
+	}
+	return v
+}
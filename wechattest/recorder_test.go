@@ -0,0 +1,113 @@
+package wechattest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "wechattest")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := NewServer()
+	server.AddCode("CODE", Session{OpenID: "OPENID", SessionKey: "SESSIONKEY"})
+
+	recorder := NewRecorder(dir, ModeRecord, nil)
+	client := recorder.Client()
+
+	tokenURL := "/cgi-bin/token?grant_type=client_credential&appid=APPID&secret=SECRET"
+	loginURL := "/sns/jscode2session?appid=APPID&secret=SECRET&js_code=CODE&grant_type=authorization_code"
+	datacubeURL := "/datacube/getweanalysisappiddailysummarytrend?access_token=ACCESS_TOKEN_1"
+
+	do := func(method, path, body string) string {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return string(data)
+	}
+
+	if body := do(http.MethodGet, tokenURL, ""); !strings.Contains(body, "ACCESS_TOKEN_1") {
+		t.Fatalf("record token: %s", body)
+	}
+	do(http.MethodGet, loginURL, "")
+	if body := do(http.MethodGet, loginURL, ""); !strings.Contains(body, "40163") {
+		t.Fatalf("record login: %s", body)
+	}
+	do(http.MethodPost, datacubeURL, `{"begin_date":"20170313","end_date":"20170313"}`)
+	server.Close()
+
+	data, _ := ioutil.ReadFile(filepath.Join(dir, "sns_jscode2session.json"))
+	if strings.Contains(string(data), "SECRET") || strings.Contains(string(data), "SESSIONKEY") || !strings.Contains(string(data), "OPENID") {
+		t.Fatalf("fixture not redacted: %s", data)
+	}
+	data, _ = ioutil.ReadFile(filepath.Join(dir, "cgi-bin_token.json"))
+	if strings.Contains(string(data), "ACCESS_TOKEN_1") {
+		t.Fatalf("fixture not redacted: %s", data)
+	}
+
+	// 回放不发出请求，access_token 等取值不同也能匹配
+	recorder = NewRecorder(dir, ModeReplay, nil)
+	client = recorder.Client()
+
+	if body := do(http.MethodGet, tokenURL, ""); body != `{"access_token":"REDACTED","expires_in":7200}` {
+		t.Fatalf("replay token: %s", body)
+	}
+	if body := do(http.MethodGet, loginURL, ""); body != `{"openid":"OPENID","session_key":"REDACTED"}` {
+		t.Fatalf("replay login: %s", body)
+	}
+	for i := 0; i < 2; i++ {
+		if body := do(http.MethodGet, loginURL, ""); !strings.Contains(body, "40163") {
+			t.Fatalf("replay login %d: %s", i, body)
+		}
+	}
+	if body := do(http.MethodPost, "/datacube/getweanalysisappiddailysummarytrend?access_token=OTHER",
+		`{"end_date":"20170313","begin_date":"20170313"}`); body != `{}` {
+		t.Fatalf("replay datacube: %s", body)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "http://localhost"+datacubeURL, strings.NewReader(`{"begin_date":"20170314","end_date":"20170314"}`))
+	if _, err = client.Do(req); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestRecorderDoesNotModifyRequest(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "wechattest")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := NewServer()
+	defer server.Close()
+
+	recorder := NewRecorder(dir, ModeRecord, nil)
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/datacube/getweanalysisappiddailysummarytrend?access_token=x", strings.NewReader(`{}`))
+	body := req.Body
+	resp, err := recorder.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	resp.Body.Close()
+	if req.Body != body {
+		t.Fatal("RoundTrip should not replace the caller's request body")
+	}
+	if r, ok := server.LastRequest("/datacube/getweanalysisappiddailysummarytrend"); !ok || string(r.Body) != `{}` {
+		t.Fatalf("request: %+v", r)
+	}
+}
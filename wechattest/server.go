@@ -0,0 +1,726 @@
+// Package wechattest 提供本地模拟的微信接口服务，用于集成测试，无需访问 api.weixin.qq.com。
+//
+// 服务内置 /cgi-bin/token、/sns/jscode2session、/wxa/getpaidunionid、/wxa/checksession、
+// /wxa/resetusersessionkey、/wxa/business/getuserphonenumber、/datacube/*、客服消息
+// /cgi-bin/message/custom/* 与临时素材 /cgi-bin/media/* 的默认行为，
+// 校验凭证与 access_token 并返回与微信一致的错误码；也可按接口设置固定响应、注入错误码，
+// 并记录全部请求用于断言：
+//
+//	server := wechattest.NewServer()
+//	defer server.Close()
+//
+//	server.AddCode("CODE", wechattest.Session{OpenID: "OPENID", SessionKey: "SESSIONKEY"})
+//	server.FailNext("/sns/jscode2session", 45011, "api minute-quota reach limit")
+//
+//	client := wechat.NewClient(wechat.WithBaseURL(server.URL), wechat.WithCredential(server.AppID, server.Secret))
+//
+// 本包不依赖 wechat 包，wechat 包内部测试也可使用
+package wechattest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	// DefaultAppID 默认小程序 appId
+	DefaultAppID = "APPID"
+	// DefaultSecret 默认小程序 appSecret
+	DefaultSecret = "SECRET"
+	// DefaultExpiresIn 签发的 access_token 有效期，单位秒
+	DefaultExpiresIn = 7200
+)
+
+// 与微信一致的错误码
+const (
+	errCodeInvalidCredential  = 40001
+	errCodeInvalidGrantType   = 40002
+	errCodeInvalidOpenID      = 40003
+	errCodeInvalidMediaType   = 40004
+	errCodeInvalidMediaID     = 40007
+	errCodeInvalidMsgType     = 40008
+	errCodeInvalidAppID       = 40013
+	errCodeInvalidCode        = 40029
+	errCodeInvalidArgs        = 40097
+	errCodeInvalidAppSecret   = 40125
+	errCodeCodeUsed           = 40163
+	errCodeMediaDataMissing   = 41005
+	errCodeAccessTokenExpired = 42001
+	errCodeDateFormat         = 61500
+	errCodeInvalidSignature   = 87009
+	errCodeInvalidOrder       = 89300
+)
+
+// Request 服务收到的请求
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// JSON 将请求体解析到 v
+func (r Request) JSON(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Response 脚本化的响应
+type Response struct {
+	Status int         // http 状态码，默认 200
+	Body   interface{} // 响应体，[]byte 与 string 原样返回，其他类型编码为 JSON
+}
+
+// ErrCode 微信错误码响应
+func ErrCode(code int, msg string) Response {
+	return Response{Body: map[string]interface{}{"errcode": code, "errmsg": msg}}
+}
+
+// Session 登录 code 对应的会话
+type Session struct {
+	OpenID     string `json:"openid"`
+	SessionKey string `json:"session_key"`
+	UnionID    string `json:"unionid,omitempty"`
+}
+
+// Phone 手机号获取凭证对应的手机号
+type Phone struct {
+	PhoneNumber     string `json:"phoneNumber"`
+	PurePhoneNumber string `json:"purePhoneNumber"`
+	CountryCode     string `json:"countryCode"`
+}
+
+// Media 临时素材
+type Media struct {
+	ContentType string // 下载时的 Content-Type，为空时根据内容推断
+	FileName    string // 下载时 Content-Disposition 中的文件名
+	Data        []byte
+}
+
+// Server 模拟的微信接口服务，URL 为服务地址，可用于 wechat.WithBaseURL
+type Server struct {
+	*httptest.Server
+
+	AppID  string // 接受的 appId
+	Secret string // 接受的 appSecret
+
+	mu        sync.Mutex
+	tokens    map[string]bool // 已签发的 access_token，false 表示已过期
+	issued    int
+	sessions  map[string]Session
+	usedCodes map[string]bool
+	keys      map[string]string // openid => 当前 session_key
+	resets    int
+	unionIDs  map[string]string // openid => unionid
+	phones    map[string]Phone  // 手机号获取凭证 => 手机号
+	media     map[string]Media  // media_id => 临时素材
+	replies   map[string]Response
+	queues    map[string][]Response
+	handlers  map[string]http.HandlerFunc
+	requests  []Request
+}
+
+// Option 服务配置项
+type Option func(*Server)
+
+// WithCredential 设置服务接受的 appId 与 appSecret，默认 DefaultAppID、DefaultSecret
+func WithCredential(appID, secret string) Option {
+	return func(s *Server) {
+		s.AppID = appID
+		s.Secret = secret
+	}
+}
+
+// NewServer 创建并启动服务，使用完毕后调用 Close
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		AppID:     DefaultAppID,
+		Secret:    DefaultSecret,
+		tokens:    make(map[string]bool),
+		sessions:  make(map[string]Session),
+		usedCodes: make(map[string]bool),
+		keys:      make(map[string]string),
+		unionIDs:  make(map[string]string),
+		phones:    make(map[string]Phone),
+		media:     make(map[string]Media),
+		replies:   make(map[string]Response),
+		queues:    make(map[string][]Response),
+		handlers:  make(map[string]http.HandlerFunc),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Handle 设置接口的处理函数，替换默认行为，path 如 /wxa/getpaidunionid
+func (s *Server) Handle(path string, handler http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[path] = handler
+}
+
+// Reply 设置接口的固定响应，替换默认行为；access_token 仍会校验
+func (s *Server) Reply(path string, response Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies[path] = response
+}
+
+// ReplyJSON 同 Reply，响应体为 body 编码后的 JSON
+func (s *Server) ReplyJSON(path string, body interface{}) {
+	s.Reply(path, Response{Body: body})
+}
+
+// Enqueue 追加一次性响应，按顺序用于接下来的请求，用完后恢复原有行为
+func (s *Server) Enqueue(path string, responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queues[path] = append(s.queues[path], responses...)
+}
+
+// FailNext 接下来的一次请求返回错误码 code
+func (s *Server) FailNext(path string, code int, msg string) {
+	s.Enqueue(path, ErrCode(code, msg))
+}
+
+// AddCode 登记 wx.login 获取的 code，jscode2session 每个 code 只能使用一次；
+// 登记后 session.SessionKey 成为该用户当前的 session_key
+func (s *Server) AddCode(code string, session Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[code] = session
+	delete(s.usedCodes, code)
+	s.keys[session.OpenID] = session.SessionKey
+}
+
+// SessionKey 用户当前的 session_key，登录或重置后更新
+func (s *Server) SessionKey(openID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys[openID]
+}
+
+// AddPaidUnionID 登记已支付用户的 UnionID
+func (s *Server) AddPaidUnionID(openID, unionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unionIDs[openID] = unionID
+}
+
+// AddPhoneCode 登记 getPhoneNumber 返回的 code，每个 code 只能使用一次
+func (s *Server) AddPhoneCode(code string, phone Phone) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.phones[code] = phone
+}
+
+// AddMedia 登记临时素材，返回可用于 /cgi-bin/media/get 的 media_id
+func (s *Server) AddMedia(media Media) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addMedia(media)
+}
+
+// Media 已上传或登记的临时素材
+func (s *Server) Media(mediaID string) (Media, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	media, ok := s.media[mediaID]
+	return media, ok
+}
+
+// addMedia 保存临时素材，调用方需持有锁
+func (s *Server) addMedia(media Media) string {
+	mediaID := fmt.Sprintf("MEDIA_ID_%d", len(s.media)+1)
+	s.media[mediaID] = media
+	return mediaID
+}
+
+// IssueToken 签发一个有效的 access_token，可用于直接传入 accessToken 的调用
+func (s *Server) IssueToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.issueToken()
+}
+
+// issueToken 签发 access_token，调用方需持有锁
+func (s *Server) issueToken() string {
+	s.issued++
+	token := fmt.Sprintf("ACCESS_TOKEN_%d", s.issued)
+	s.tokens[token] = true
+	return token
+}
+
+// ExpireTokens 使已签发的 access_token 全部过期，之后使用它们的请求返回 42001
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token := range s.tokens {
+		s.tokens[token] = false
+	}
+}
+
+// Requests 收到的请求，path 为空时返回全部
+func (s *Server) Requests(path string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var requests []Request
+	for _, r := range s.requests {
+		if path == "" || r.Path == path {
+			requests = append(requests, r)
+		}
+	}
+	return requests
+}
+
+// LastRequest 接口最近一次收到的请求，没有请求时 ok 为 false
+func (s *Server) LastRequest(path string) (request Request, ok bool) {
+	requests := s.Requests(path)
+	if len(requests) == 0 {
+		return request, false
+	}
+	return requests[len(requests)-1], true
+}
+
+// Reset 清空请求记录
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+// AssertCalled 断言接口收到 n 次请求
+func (s *Server) AssertCalled(t testing.TB, path string, n int) {
+	t.Helper()
+	if got := len(s.Requests(path)); got != n {
+		t.Fatalf("wechattest: %s called %d times, want %d", path, got, n)
+	}
+}
+
+// AssertQuery 断言接口最近一次请求的 query 参数 name 为 value
+func (s *Server) AssertQuery(t testing.TB, path, name, value string) {
+	t.Helper()
+	r, ok := s.LastRequest(path)
+	if !ok {
+		t.Fatalf("wechattest: %s not called", path)
+	}
+	if got := r.Query.Get(name); got != value {
+		t.Fatalf("wechattest: %s query %s = %q, want %q", path, name, got, value)
+	}
+}
+
+// serveHTTP 记录请求后依次使用一次性响应、处理函数、固定响应或默认行为
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+
+	if queue := s.queues[r.URL.Path]; len(queue) > 0 {
+		s.queues[r.URL.Path] = queue[1:]
+		s.mu.Unlock()
+		writeResponse(w, queue[0])
+		return
+	}
+	handler, hasHandler := s.handlers[r.URL.Path]
+	reply, hasReply := s.replies[r.URL.Path]
+	s.mu.Unlock()
+
+	if hasHandler {
+		handler(w, r)
+		return
+	}
+
+	switch path := r.URL.Path; {
+	case path == "/cgi-bin/token":
+		s.token(w, r)
+	case path == "/sns/jscode2session":
+		s.code2Session(w, r)
+	case hasReply:
+		if s.checkToken(w, r) {
+			writeResponse(w, reply)
+		}
+	case path == "/wxa/getpaidunionid":
+		s.paidUnionID(w, r)
+	case path == "/wxa/business/getuserphonenumber":
+		s.phoneNumber(w, r)
+	case path == "/wxa/checksession":
+		s.checkSession(w, r)
+	case path == "/wxa/resetusersessionkey":
+		s.resetSessionKey(w, r)
+	case strings.HasPrefix(path, "/datacube/"):
+		s.datacube(w, r)
+	case path == "/cgi-bin/message/custom/send":
+		s.sendMessage(w, r)
+	case path == "/cgi-bin/message/custom/typing":
+		s.typing(w, r)
+	case path == "/cgi-bin/media/upload":
+		s.uploadMedia(w, r)
+	case path == "/cgi-bin/media/get":
+		s.getMedia(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// token /cgi-bin/token
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+
+	if response, ok := s.reply(r.URL.Path); ok {
+		writeResponse(w, response)
+		return
+	}
+	query := r.URL.Query()
+	if query.Get("grant_type") != "client_credential" {
+		writeResponse(w, ErrCode(errCodeInvalidGrantType, "invalid grant_type"))
+		return
+	}
+	if !s.checkCredential(w, query) {
+		return
+	}
+
+	s.mu.Lock()
+	token := s.issueToken()
+	s.mu.Unlock()
+
+	writeResponse(w, Response{Body: map[string]interface{}{"access_token": token, "expires_in": DefaultExpiresIn}})
+}
+
+// code2Session /sns/jscode2session
+func (s *Server) code2Session(w http.ResponseWriter, r *http.Request) {
+
+	if response, ok := s.reply(r.URL.Path); ok {
+		writeResponse(w, response)
+		return
+	}
+	query := r.URL.Query()
+	if !s.checkCredential(w, query) {
+		return
+	}
+	if query.Get("grant_type") != "authorization_code" {
+		writeResponse(w, ErrCode(errCodeInvalidGrantType, "invalid grant_type"))
+		return
+	}
+
+	code := query.Get("js_code")
+	s.mu.Lock()
+	session, ok := s.sessions[code]
+	used := s.usedCodes[code]
+	s.usedCodes[code] = true
+	s.mu.Unlock()
+
+	switch {
+	case !ok:
+		writeResponse(w, ErrCode(errCodeInvalidCode, "invalid code"))
+	case used:
+		writeResponse(w, ErrCode(errCodeCodeUsed, "code been used"))
+	default:
+		writeResponse(w, Response{Body: session})
+	}
+}
+
+// paidUnionID /wxa/getpaidunionid
+func (s *Server) paidUnionID(w http.ResponseWriter, r *http.Request) {
+
+	if !s.checkToken(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	unionID, ok := s.unionIDs[r.URL.Query().Get("openid")]
+	s.mu.Unlock()
+
+	if !ok {
+		writeResponse(w, ErrCode(errCodeInvalidOrder, "invalid order"))
+		return
+	}
+	writeResponse(w, Response{Body: map[string]string{"unionid": unionID}})
+}
+
+// phoneNumber /wxa/business/getuserphonenumber
+func (s *Server) phoneNumber(w http.ResponseWriter, r *http.Request) {
+
+	if !s.checkToken(w, r) {
+		return
+	}
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	data, _ := ioutil.ReadAll(r.Body)
+	json.Unmarshal(data, &body)
+
+	s.mu.Lock()
+	phone, ok := s.phones[body.Code]
+	delete(s.phones, body.Code)
+	s.mu.Unlock()
+
+	if !ok {
+		writeResponse(w, ErrCode(errCodeInvalidCode, "invalid code"))
+		return
+	}
+	writeResponse(w, Response{Body: map[string]interface{}{
+		"errcode": 0,
+		"errmsg":  "ok",
+		"phone_info": map[string]interface{}{
+			"phoneNumber":     phone.PhoneNumber,
+			"purePhoneNumber": phone.PurePhoneNumber,
+			"countryCode":     phone.CountryCode,
+			"watermark":       map[string]interface{}{"appid": s.AppID, "timestamp": time.Now().Unix()},
+		},
+	}})
+}
+
+// checkSession /wxa/checksession
+func (s *Server) checkSession(w http.ResponseWriter, r *http.Request) {
+	if s.checkToken(w, r) && s.checkSignature(w, r.URL.Query()) {
+		writeResponse(w, ErrCode(0, "ok"))
+	}
+}
+
+// resetSessionKey /wxa/resetusersessionkey，签发新的 session_key
+func (s *Server) resetSessionKey(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+	if !s.checkToken(w, r) || !s.checkSignature(w, query) {
+		return
+	}
+
+	openID := query.Get("openid")
+	s.mu.Lock()
+	s.resets++
+	key := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("SESSION_KEY_%04d", s.resets)))
+	s.keys[openID] = key
+	s.mu.Unlock()
+
+	writeResponse(w, Response{Body: map[string]interface{}{"errcode": 0, "errmsg": "ok", "openid": openID, "session_key": key}})
+}
+
+// checkSignature 校验用户登录态签名，即以当前 session_key 为密钥对空字符串做 HMAC-SHA256
+func (s *Server) checkSignature(w http.ResponseWriter, query url.Values) bool {
+
+	s.mu.Lock()
+	key, ok := s.keys[query.Get("openid")]
+	s.mu.Unlock()
+
+	mac := hmac.New(sha256.New, []byte(key))
+	if !ok || query.Get("sig_method") != "hmac_sha256" || query.Get("signature") != hex.EncodeToString(mac.Sum(nil)) {
+		writeResponse(w, ErrCode(errCodeInvalidSignature, "invalid signature"))
+		return false
+	}
+	return true
+}
+
+// datacube /datacube/*，未设置固定响应时校验日期后返回空数据
+func (s *Server) datacube(w http.ResponseWriter, r *http.Request) {
+
+	if !s.checkToken(w, r) {
+		return
+	}
+
+	var body struct {
+		BeginDate string `json:"begin_date"`
+		EndDate   string `json:"end_date"`
+	}
+	data, _ := ioutil.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil || len(body.BeginDate) != 8 || len(body.EndDate) != 8 {
+		writeResponse(w, ErrCode(errCodeDateFormat, "invalid date format"))
+		return
+	}
+	writeResponse(w, Response{Body: map[string]interface{}{}})
+}
+
+// sendMessage /cgi-bin/message/custom/send，校验接收者与消息类型
+func (s *Server) sendMessage(w http.ResponseWriter, r *http.Request) {
+
+	if !s.checkToken(w, r) {
+		return
+	}
+
+	var body map[string]json.RawMessage
+	data, _ := ioutil.ReadAll(r.Body)
+	json.Unmarshal(data, &body)
+
+	var toUser, msgType string
+	json.Unmarshal(body["touser"], &toUser)
+	json.Unmarshal(body["msgtype"], &msgType)
+
+	switch msgType {
+	case "text", "image", "link", "miniprogrampage":
+	default:
+		writeResponse(w, ErrCode(errCodeInvalidMsgType, "invalid message type"))
+		return
+	}
+	if toUser == "" {
+		writeResponse(w, ErrCode(errCodeInvalidOpenID, "invalid openid"))
+		return
+	}
+	if _, ok := body[msgType]; !ok {
+		writeResponse(w, ErrCode(errCodeInvalidArgs, "invalid args"))
+		return
+	}
+	writeResponse(w, ErrCode(0, "ok"))
+}
+
+// typing /cgi-bin/message/custom/typing
+func (s *Server) typing(w http.ResponseWriter, r *http.Request) {
+
+	if !s.checkToken(w, r) {
+		return
+	}
+
+	var body struct {
+		ToUser  string `json:"touser"`
+		Command string `json:"command"`
+	}
+	data, _ := ioutil.ReadAll(r.Body)
+	json.Unmarshal(data, &body)
+
+	switch {
+	case body.ToUser == "":
+		writeResponse(w, ErrCode(errCodeInvalidOpenID, "invalid openid"))
+	case body.Command != "Typing" && body.Command != "CancelTyping":
+		writeResponse(w, ErrCode(errCodeInvalidArgs, "invalid args"))
+	default:
+		writeResponse(w, ErrCode(0, "ok"))
+	}
+}
+
+// uploadMedia /cgi-bin/media/upload，仅支持 type=image，文件字段名为 media
+func (s *Server) uploadMedia(w http.ResponseWriter, r *http.Request) {
+
+	if !s.checkToken(w, r) {
+		return
+	}
+	if r.URL.Query().Get("type") != "image" {
+		writeResponse(w, ErrCode(errCodeInvalidMediaType, "invalid media type"))
+		return
+	}
+
+	file, header, err := r.FormFile("media")
+	if err != nil {
+		writeResponse(w, ErrCode(errCodeMediaDataMissing, "media data missing"))
+		return
+	}
+	defer file.Close()
+	data, _ := ioutil.ReadAll(file)
+
+	s.mu.Lock()
+	mediaID := s.addMedia(Media{FileName: header.Filename, Data: data})
+	s.mu.Unlock()
+
+	writeResponse(w, Response{Body: map[string]interface{}{"type": "image", "media_id": mediaID, "created_at": time.Now().Unix()}})
+}
+
+// getMedia /cgi-bin/media/get，返回文件内容，media_id 无效时返回 JSON 错误码
+func (s *Server) getMedia(w http.ResponseWriter, r *http.Request) {
+
+	if !s.checkToken(w, r) {
+		return
+	}
+
+	media, ok := s.Media(r.URL.Query().Get("media_id"))
+	if !ok {
+		writeResponse(w, ErrCode(errCodeInvalidMediaID, "invalid media_id"))
+		return
+	}
+
+	contentType := media.ContentType
+	if contentType == "" {
+		contentType = http.DetectContentType(media.Data)
+	}
+	w.Header().Set("Content-Type", contentType)
+	if media.FileName != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": media.FileName}))
+	}
+	w.Write(media.Data)
+}
+
+// reply 接口的固定响应
+func (s *Server) reply(path string) (Response, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	response, ok := s.replies[path]
+	return response, ok
+}
+
+// checkCredential 校验 appid 与 secret，失败时写入错误码
+func (s *Server) checkCredential(w http.ResponseWriter, query url.Values) bool {
+	switch {
+	case query.Get("appid") != s.AppID:
+		writeResponse(w, ErrCode(errCodeInvalidAppID, "invalid appid"))
+	case query.Get("secret") != s.Secret:
+		writeResponse(w, ErrCode(errCodeInvalidAppSecret, "invalid appsecret"))
+	default:
+		return true
+	}
+	return false
+}
+
+// checkToken 校验 access_token，失败时写入 40001 或 42001
+func (s *Server) checkToken(w http.ResponseWriter, r *http.Request) bool {
+
+	s.mu.Lock()
+	valid, issued := s.tokens[r.URL.Query().Get("access_token")]
+	s.mu.Unlock()
+
+	switch {
+	case !issued:
+		writeResponse(w, ErrCode(errCodeInvalidCredential, "invalid credential, access_token is invalid or not latest"))
+	case !valid:
+		writeResponse(w, ErrCode(errCodeAccessTokenExpired, "access_token expired"))
+	default:
+		return true
+	}
+	return false
+}
+
+// writeResponse 写入响应
+func writeResponse(w http.ResponseWriter, response Response) {
+
+	var body []byte
+	switch b := response.Body.(type) {
+	case []byte:
+		body = b
+	case string:
+		body = []byte(b)
+	default:
+		var err error
+		if body, err = json.Marshal(b); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+
+	status := response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
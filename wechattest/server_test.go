@@ -0,0 +1,89 @@
+package wechattest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func post(t *testing.T, url, body string) string {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(resp.Body)
+	return string(data)
+}
+
+func TestServer(t *testing.T) {
+
+	server := NewServer(WithCredential("wx1", "s1"))
+	defer server.Close()
+
+	_, body := get(t, server.URL+"/cgi-bin/token?grant_type=client_credential&appid=wx1&secret=s1")
+	if body != `{"access_token":"ACCESS_TOKEN_1","expires_in":7200}` {
+		t.Fatalf("token: %s", body)
+	}
+	if _, body = get(t, server.URL+"/cgi-bin/token?grant_type=client_credential&appid=wx1&secret=s2"); !strings.Contains(body, "40125") {
+		t.Fatalf("token: %s", body)
+	}
+
+	datacube := server.URL + "/datacube/getweanalysisappiddailysummarytrend?access_token="
+	if body = post(t, datacube+"ACCESS_TOKEN_1", `{"begin_date":"20170313","end_date":"20170313"}`); body != `{}` {
+		t.Fatalf("datacube: %s", body)
+	}
+	if body = post(t, datacube+"ACCESS_TOKEN_1", `{"begin_date":"2017-03-13"}`); !strings.Contains(body, "61500") {
+		t.Fatalf("datacube: %s", body)
+	}
+	if body = post(t, datacube+"unknown", `{}`); !strings.Contains(body, "40001") {
+		t.Fatalf("datacube: %s", body)
+	}
+
+	server.ReplyJSON("/datacube/getweanalysisappiddailysummarytrend", map[string]interface{}{"list": []interface{}{}})
+	server.Enqueue("/datacube/getweanalysisappiddailysummarytrend", Response{Status: http.StatusBadGateway, Body: "bad gateway"})
+	if body = post(t, datacube+"ACCESS_TOKEN_1", `{}`); body != "bad gateway" {
+		t.Fatalf("enqueued: %s", body)
+	}
+	if body = post(t, datacube+"ACCESS_TOKEN_1", `{}`); body != `{"list":[]}` {
+		t.Fatalf("reply: %s", body)
+	}
+	server.ExpireTokens()
+	if body = post(t, datacube+"ACCESS_TOKEN_1", `{}`); !strings.Contains(body, "42001") {
+		t.Fatalf("expired: %s", body)
+	}
+
+	server.Handle("/wxa/custom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	if status, _ := get(t, server.URL+"/wxa/custom?a=1"); status != http.StatusTeapot {
+		t.Fatalf("status: %d", status)
+	}
+	if status, _ := get(t, server.URL+"/unknown"); status != http.StatusNotFound {
+		t.Fatalf("status: %d", status)
+	}
+
+	server.AssertCalled(t, "/datacube/getweanalysisappiddailysummarytrend", 6)
+	server.AssertQuery(t, "/wxa/custom", "a", "1")
+	if r, ok := server.LastRequest("/datacube/getweanalysisappiddailysummarytrend"); !ok || r.Method != http.MethodPost || string(r.Body) != `{}` {
+		t.Fatalf("last request: %+v", r)
+	}
+	if len(server.Requests("")) != 10 {
+		t.Fatalf("requests: %d", len(server.Requests("")))
+	}
+	server.Reset()
+	server.AssertCalled(t, "", 0)
+}